@@ -0,0 +1,263 @@
+package s
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 参数的来源
+type argSources struct {
+	path  map[string]interface{}
+	query map[string]interface{}
+	body  map[string]interface{}
+}
+
+// 使用 s:"path"、s:"query"、s:"header"、s:"cookie"、s:"body" 指定来源的字段
+type fieldBinding struct {
+	name   string
+	source string
+	key    string
+}
+
+var bindingSources = map[string]bool{"path": true, "query": true, "header": true, "cookie": true, "body": true}
+
+// 合并所有来源的参数，优先级为：路径 > 请求内容 > QueryString
+func (sources *argSources) merge() map[string]interface{} {
+	args := make(map[string]interface{}, len(sources.path)+len(sources.query)+len(sources.body))
+	for _, from := range []map[string]interface{}{sources.query, sources.body, sources.path} {
+		for k, v := range from {
+			args[k] = v
+		}
+	}
+	return args
+}
+
+// 表单和 QueryString 的参数，支持 a[b][c]、a.b、a[] 格式的嵌套参数，a[0][b] 格式生成数组
+func setFormArgs(args map[string]interface{}, values map[string][]string) {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	// 按顺序处理，同名的普通参数和嵌套参数同时存在时使用嵌套参数
+	sort.Strings(keys)
+	nestedKeys := map[string]bool{}
+	for _, k := range keys {
+		v := values[k]
+		if len(v) == 0 {
+			continue
+		}
+		path := parseFormKey(k)
+		if len(path) == 1 {
+			if len(v) > 1 {
+				args[k] = v
+			} else {
+				args[k] = v[0]
+			}
+			continue
+		}
+		setNestedArg(args, path, v)
+		nestedKeys[path[0]] = true
+	}
+	for k := range nestedKeys {
+		args[k] = makeIndexedSlices(args[k])
+	}
+}
+
+// 拆分参数名，a[b][c] 和 a.b.c 都拆分为 [a b c]，a[] 拆分为 [a ""]，格式不正确时不拆分
+func parseFormKey(key string) []string {
+	if !strings.ContainsAny(key, "[.") {
+		return []string{key}
+	}
+	path := make([]string, 0)
+	start := 0
+	for i := 0; i < len(key); i++ {
+		switch key[i] {
+		case '.':
+			if i == start {
+				return []string{key}
+			}
+			path = append(path, key[start:i])
+			start = i + 1
+		case '[':
+			end := strings.IndexByte(key[i:], ']')
+			if end < 0 || (i == start && len(path) == 0) {
+				return []string{key}
+			}
+			if i > start {
+				path = append(path, key[start:i])
+			}
+			path = append(path, key[i+1:i+end])
+			i += end
+			start = i + 1
+			if start < len(key) && key[start] == '.' {
+				i++
+				start++
+			}
+		}
+	}
+	if start < len(key) {
+		path = append(path, key[start:])
+	} else if key[len(key)-1] == '.' {
+		return []string{key}
+	}
+	// 空的名称只能在最后表示数组
+	for i, name := range path {
+		if name == "" && i != len(path)-1 {
+			return []string{key}
+		}
+	}
+	return path
+}
+
+func setNestedArg(args map[string]interface{}, path []string, values []string) {
+	current := args
+	for i := 0; i < len(path)-1; i++ {
+		key := path[i]
+		if path[i+1] == "" {
+			list, _ := current[key].([]interface{})
+			for _, v := range values {
+				list = append(list, v)
+			}
+			current[key] = list
+			return
+		}
+		child, ok := current[key].(map[string]interface{})
+		if !ok {
+			child = map[string]interface{}{}
+			current[key] = child
+		}
+		current = child
+	}
+	if len(values) > 1 {
+		current[path[len(path)-1]] = values
+	} else {
+		current[path[len(path)-1]] = values[0]
+	}
+}
+
+// 名称都是数字的 map 转换为按数字排序的数组
+func makeIndexedSlices(value interface{}) interface{} {
+	m, ok := value.(map[string]interface{})
+	if !ok {
+		return value
+	}
+	indexes := make([]int, 0, len(m))
+	for k, v := range m {
+		m[k] = makeIndexedSlices(v)
+		if index, err := strconv.Atoi(k); err == nil && index >= 0 && strconv.Itoa(index) == k {
+			indexes = append(indexes, index)
+		}
+	}
+	if len(indexes) == 0 || len(indexes) != len(m) {
+		return m
+	}
+	sort.Ints(indexes)
+	list := make([]interface{}, len(indexes))
+	for i, index := range indexes {
+		list[i] = m[strconv.Itoa(index)]
+	}
+	return list
+}
+
+// 解析 s tag，格式为 来源 或 来源:参数名，例如 s:"header:X-Client-Id"
+func parseBindingTag(tag string) (source, key string) {
+	if tag == "" {
+		return "", ""
+	}
+	source = tag
+	if pos := strings.IndexByte(tag, ':'); pos >= 0 {
+		source, key = tag[0:pos], tag[pos+1:]
+	}
+	return strings.ToLower(strings.TrimSpace(source)), strings.TrimSpace(key)
+}
+
+// 参数结构体中指定了来源的字段
+func getFieldBindings(t reflect.Type) []fieldBinding {
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil
+	}
+	var bindings []fieldBinding
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		source, key := parseBindingTag(field.Tag.Get("s"))
+		if source == "" {
+			continue
+		}
+		if !bindingSources[source] {
+			logError(fmt.Sprintf("unknown binding source %s", source), "type", t.String(), "field", field.Name)
+			continue
+		}
+		bindings = append(bindings, fieldBinding{name: field.Name, source: source, key: key})
+	}
+	return bindings
+}
+
+// 生成用于转换参数结构体的数据，指定了来源的字段只使用该来源的值，不会被其他来源的同名参数覆盖
+func makeBindingArgs(bindings []fieldBinding, args map[string]interface{}, sources *argSources, request *http.Request) map[string]interface{} {
+	out := make(map[string]interface{}, len(args))
+	for k, v := range args {
+		out[k] = v
+	}
+	for _, binding := range bindings {
+		for k := range out {
+			if strings.EqualFold(k, binding.name) {
+				delete(out, k)
+			}
+		}
+		if v, ok := binding.lookup(sources, request); ok {
+			out[binding.name] = v
+		}
+	}
+	return out
+}
+
+func (binding *fieldBinding) lookup(sources *argSources, request *http.Request) (interface{}, bool) {
+	key := binding.key
+	if key == "" {
+		key = binding.name
+	}
+	switch binding.source {
+	case "path":
+		return lookupArg(sources.path, key)
+	case "query":
+		return lookupArg(sources.query, key)
+	case "body":
+		return lookupArg(sources.body, key)
+	case "header":
+		// 字段名匹配时忽略大小写和 -，例如 ClientId 匹配 Client-Id
+		for k, v := range request.Header {
+			if len(v) > 0 && (strings.EqualFold(k, key) || strings.EqualFold(strings.Replace(k, "-", "", -1), key)) {
+				if len(v) > 1 {
+					return v, true
+				}
+				return v[0], true
+			}
+		}
+	case "cookie":
+		for _, cookie := range request.Cookies() {
+			if strings.EqualFold(cookie.Name, key) {
+				return cookie.Value, true
+			}
+		}
+	}
+	return nil, false
+}
+
+func lookupArg(args map[string]interface{}, key string) (interface{}, bool) {
+	if args == nil {
+		return nil, false
+	}
+	if v, ok := args[key]; ok {
+		return v, true
+	}
+	for k, v := range args {
+		if strings.EqualFold(k, key) {
+			return v, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,89 @@
+package s
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ssgo/discover"
+	"github.com/ssgo/httpclient"
+	"github.com/ssgo/log"
+)
+
+// 在服务中调用其他服务，用法与 discover.Caller 相同，作为服务的参数注入
+// 每次调用时按服务的 context 计算 X-Timeout，超时或客户端断开时立即返回 Error 为 ctx.Err() 的结果
+type Caller struct {
+	Request *http.Request
+	NoBody  bool
+	ctx     context.Context
+	logger  *log.Logger
+}
+
+type callResult struct {
+	result *httpclient.Result
+	node   string
+}
+
+func (caller *Caller) Get(app, path string, headers ...string) *httpclient.Result {
+	return caller.Do("GET", app, path, nil, headers...)
+}
+func (caller *Caller) Post(app, path string, data interface{}, headers ...string) *httpclient.Result {
+	return caller.Do("POST", app, path, data, headers...)
+}
+func (caller *Caller) Put(app, path string, data interface{}, headers ...string) *httpclient.Result {
+	return caller.Do("PUT", app, path, data, headers...)
+}
+func (caller *Caller) Delete(app, path string, data interface{}, headers ...string) *httpclient.Result {
+	return caller.Do("DELETE", app, path, data, headers...)
+}
+func (caller *Caller) Head(app, path string, data interface{}, headers ...string) *httpclient.Result {
+	return caller.Do("HEAD", app, path, data, headers...)
+}
+func (caller *Caller) Do(method, app, path string, data interface{}, headers ...string) *httpclient.Result {
+	r, _ := caller.DoWithNode(method, app, "", path, data, headers...)
+	return r
+}
+
+// 每次调用使用新的请求，带上当时剩余的处理时间，并发调用时互不影响
+func (caller *Caller) DoWithNode(method, app, withNode, path string, data interface{}, headers ...string) (*httpclient.Result, string) {
+	if err := caller.ctx.Err(); err != nil {
+		return &httpclient.Result{Error: err}, ""
+	}
+	call := discover.NewCaller(makeCallerRequest(caller.Request, caller.ctx), caller.logger)
+	call.NoBody = caller.NoBody
+	if _, ok := caller.ctx.Deadline(); !ok {
+		return call.DoWithNode(method, app, withNode, path, data, headers...)
+	}
+
+	// httpclient 不使用请求的 context，到达截止时间后不再等待调用的结果
+	done := make(chan callResult, 1)
+	go func() {
+		defer func() {
+			if err := recover(); err != nil {
+				done <- callResult{result: &httpclient.Result{Error: fmt.Errorf("%v", err)}}
+			}
+		}()
+		r, node := call.DoWithNode(method, app, withNode, path, data, headers...)
+		done <- callResult{result: r, node: node}
+	}()
+	select {
+	case r := <-done:
+		return r.result, r.node
+	case <-caller.ctx.Done():
+		return &httpclient.Result{Error: caller.ctx.Err()}, ""
+	}
+}
+
+// 剩余的处理时间（毫秒），没有截止时间时返回 0
+func remainingTimeout(ctx context.Context) int64 {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return 0
+	}
+	remains := int64(time.Until(deadline) / time.Millisecond)
+	if remains < 1 {
+		remains = 1
+	}
+	return remains
+}
@@ -0,0 +1,507 @@
+package s
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"mime"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/ssgo/u"
+)
+
+type codecType struct {
+	mediaType string
+	decoder   func([]byte, interface{}) error
+	encoder   func(interface{}) ([]byte, error)
+}
+
+var codecs = makeDefaultCodecs()
+
+func makeDefaultCodecs() map[string]*codecType {
+	return map[string]*codecType{
+		"application/json":        {mediaType: "application/json", decoder: json.Unmarshal, encoder: encodeJson},
+		"application/xml":         {mediaType: "application/xml", decoder: decodeXml, encoder: encodeXml},
+		"text/xml":                {mediaType: "text/xml", decoder: decodeXml, encoder: encodeXml},
+		"application/msgpack":     {mediaType: "application/msgpack", decoder: decodeMsgpack, encoder: encodeMsgpack},
+		"application/x-msgpack":   {mediaType: "application/x-msgpack", decoder: decodeMsgpack, encoder: encodeMsgpack},
+		"application/vnd.msgpack": {mediaType: "application/vnd.msgpack", decoder: decodeMsgpack, encoder: encodeMsgpack},
+		"text/csv":                {mediaType: "text/csv", encoder: encodeCsv},
+		"text/plain":              {mediaType: "text/plain", encoder: encodeText},
+		ndjsonType:                {mediaType: ndjsonType, encoder: encodeNdjson},
+	}
+}
+
+// 设置服务时可以使用的格式简称
+var formatAliases = map[string]string{
+	"json":    "application/json",
+	"xml":     "application/xml",
+	"msgpack": "application/msgpack",
+	"csv":     "text/csv",
+	"text":    "text/plain",
+	"ndjson":  ndjsonType,
+}
+
+type acceptItem struct {
+	mediaType string
+	q         float64
+}
+
+// 注册编解码器，按 Content-Type 解码请求内容，按 Accept 选择输出的格式
+// decoder 的用法与 json.Unmarshal 一致，v 为 *interface{} 时需要解码为 map[string]interface{} 等基础类型
+// 只用于输出的格式 decoder 可以为 nil，只用于解码的格式 encoder 可以为 nil
+// 内置了 JSON、XML（application/xml、text/xml）、MessagePack（application/msgpack、application/x-msgpack），以及只用于输出的 CSV（text/csv）和文本（text/plain）
+func RegisterCodec(mediaType string, decoder func(data []byte, v interface{}) error, encoder func(v interface{}) ([]byte, error)) {
+	mediaType = strings.ToLower(mediaType)
+	codecs[mediaType] = &codecType{mediaType: mediaType, decoder: decoder, encoder: encoder}
+}
+
+func getCodec(contentType string) *codecType {
+	if contentType == "" {
+		return nil
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil
+	}
+	if codec := codecs[mediaType]; codec != nil && codec.decoder != nil {
+		return codec
+	}
+	return nil
+}
+
+// 没有设置时只输出 JSON，其他格式需要使用 SetDefaultFormats 或 SetFormats 开启
+var defaultFormats []string
+
+// 设置服务可以输出的格式，例如 SetFormats("json", "csv") 或 SetFormats("application/json", "text/csv")
+func (sr *ServiceRegister) SetFormats(formats ...string) *ServiceRegister {
+	if sr.service != nil {
+		sr.service.formats = makeFormats(formats)
+	}
+	return sr
+}
+
+// 设置所有服务默认可以输出的格式，第一个作为默认格式，例如 SetDefaultFormats("json", "xml", "csv")
+func SetDefaultFormats(formats ...string) {
+	defaultFormats = makeFormats(formats)
+}
+
+func makeFormats(formats []string) []string {
+	out := make([]string, 0, len(formats))
+	for _, format := range formats {
+		format = strings.ToLower(strings.TrimSpace(format))
+		if formatAliases[format] != "" {
+			format = formatAliases[format]
+		}
+		out = append(out, format)
+	}
+	return out
+}
+
+// 可以输出的格式，服务没有设置时使用默认的格式，并且可以使用与请求内容相同的格式
+func getOfferedFormats(formats []string, requestCodec *codecType) []string {
+	if formats != nil {
+		return formats
+	}
+	offers := []string{"application/json"}
+	if defaultFormats != nil {
+		offers = defaultFormats
+	}
+	if requestCodec != nil && requestCodec.encoder != nil {
+		for _, offer := range offers {
+			if offer == requestCodec.mediaType {
+				return offers
+			}
+		}
+		offers = append(append(make([]string, 0, len(offers)+1), offers...), requestCodec.mediaType)
+	}
+	return offers
+}
+
+// 按 q 值从高到低排列，q 值相同时具体的类型优先于通配
+func parseAccept(accept string) []acceptItem {
+	items := make([]acceptItem, 0)
+	for _, part := range strings.Split(accept, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		item := acceptItem{q: 1}
+		params := strings.Split(part, ";")
+		item.mediaType = strings.ToLower(strings.TrimSpace(params[0]))
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if q, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					item.q = q
+				}
+			}
+		}
+		items = append(items, item)
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].q != items[j].q {
+			return items[i].q > items[j].q
+		}
+		return strings.Count(items[i].mediaType, "*") < strings.Count(items[j].mediaType, "*")
+	})
+	return items
+}
+
+// 按 Accept 选择输出的编码器，没有可以接受的格式时返回 nil
+// 没有 Accept 或接受任意格式时优先使用请求内容的格式
+func negotiateCodec(accept string, formats []string, requestCodec *codecType) *codecType {
+	offers := getOfferedFormats(formats, requestCodec)
+	offered := func(mediaType string) bool {
+		for _, offer := range offers {
+			if offer == mediaType {
+				return codecs[offer] != nil && codecs[offer].encoder != nil
+			}
+		}
+		return false
+	}
+
+	items := parseAccept(accept)
+	rejected := map[string]bool{}
+	for _, item := range items {
+		if item.q <= 0 {
+			rejected[item.mediaType] = true
+		}
+	}
+	defaultCodec := func(prefix string) *codecType {
+		if prefix == "" && requestCodec != nil && !rejected[requestCodec.mediaType] && offered(requestCodec.mediaType) {
+			return requestCodec
+		}
+		for _, offer := range offers {
+			if strings.HasPrefix(offer, prefix) && !rejected[offer] && offered(offer) {
+				return codecs[offer]
+			}
+		}
+		return nil
+	}
+
+	if len(items) == 0 {
+		return defaultCodec("")
+	}
+	for _, item := range items {
+		if item.q <= 0 {
+			continue
+		}
+		if item.mediaType == "*/*" || item.mediaType == "*" {
+			if codec := defaultCodec(""); codec != nil {
+				return codec
+			}
+		} else if strings.HasSuffix(item.mediaType, "/*") {
+			if codec := defaultCodec(item.mediaType[0 : len(item.mediaType)-1]); codec != nil {
+				return codec
+			}
+		} else if offered(item.mediaType) {
+			return codecs[item.mediaType]
+		}
+	}
+	return nil
+}
+
+// 解码请求内容，对象的字段作为参数，其他类型的数据使用 request 作为参数名
+// 注册的编解码器解码后同样检查嵌套层数
+func decodeBody(codec *codecType, data []byte, args map[string]interface{}) error {
+	value := new(interface{})
+	if err := codec.decoder(data, value); err != nil {
+		return err
+	}
+	if !checkValueDepth(*value, Config.MaxJsonDepth) {
+		return errJsonTooDeep
+	}
+	if m, ok := (*value).(map[string]interface{}); ok {
+		for k, v := range m {
+			args[k] = v
+		}
+	} else {
+		args["request"] = value
+	}
+	return nil
+}
+
+func encodeJson(data interface{}) ([]byte, error) {
+	return makeBytesResult(data), nil
+}
+
+// 编码器不支持的数据，使用 JSON 输出并且不记录错误
+var errUseJson = fmt.Errorf("use json")
+
+// 基础类型直接输出，其他类型使用 JSON（Content-Type 为 application/json）
+func encodeText(data interface{}) ([]byte, error) {
+	v := reflect.ValueOf(data)
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Map, reflect.Struct, reflect.Slice, reflect.Array, reflect.Ptr, reflect.Interface, reflect.Invalid:
+		return nil, errUseJson
+	}
+	return []byte(u.String(v.Interface())), nil
+}
+
+// 服务返回的结果是否直接输出，没有返回值、string 和 []byte 不需要协商格式
+func isRawOutType(t reflect.Type) bool {
+	if t == nil {
+		return true
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.String || (t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8)
+}
+
+// 返回 interface{} 或 Result 时，输出时才能确定结果的类型
+func isDynamicOutType(t reflect.Type) bool {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t != nil && (t.Kind() == reflect.Interface || t == reflect.TypeOf(Result{}))
+}
+
+// 输出对象的数组，第一行为字段名，结构体按字段的顺序，Map 按字段名排序
+func encodeCsv(data interface{}) ([]byte, error) {
+	v := reflect.ValueOf(data)
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return nil, fmt.Errorf("csv: unsupported type %s", reflect.TypeOf(data))
+	}
+
+	var keys []string
+	elemType := v.Type().Elem()
+	for elemType.Kind() == reflect.Ptr {
+		elemType = elemType.Elem()
+	}
+	if elemType.Kind() == reflect.Struct {
+		for i := 0; i < elemType.NumField(); i++ {
+			if elemType.Field(i).PkgPath == "" {
+				if name, _ := fieldOutputName(elemType.Field(i)); name != "" {
+					keys = append(keys, name)
+				}
+			}
+		}
+	}
+
+	generic, err := makeGenericResult(data)
+	if err != nil {
+		return nil, err
+	}
+	rows, _ := generic.([]interface{})
+	if keys == nil {
+		keySet := map[string]bool{}
+		for _, row := range rows {
+			m, ok := row.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("csv: unsupported type %s", reflect.TypeOf(data))
+			}
+			for k := range m {
+				if !keySet[k] {
+					keySet[k] = true
+					keys = append(keys, k)
+				}
+			}
+		}
+		sort.Strings(keys)
+	}
+
+	buf := new(bytes.Buffer)
+	writer := csv.NewWriter(buf)
+	_ = writer.Write(keys)
+	for _, row := range rows {
+		m, _ := row.(map[string]interface{})
+		record := make([]string, len(keys))
+		for i, k := range keys {
+			switch value := m[k].(type) {
+			case nil:
+			case string, json.Number, bool:
+				record[i] = u.String(value)
+			default:
+				record[i] = string(makeBytesResult(value))
+			}
+		}
+		_ = writer.Write(record)
+	}
+	writer.Flush()
+	return buf.Bytes(), writer.Error()
+}
+
+// 转换为 JSON 中的基础类型，字段名与 JSON 输出一致
+func makeGenericResult(data interface{}) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(makeBytesResult(data)))
+	decoder.UseNumber()
+	var out interface{}
+	err := decoder.Decode(&out)
+	return out, err
+}
+
+// XML 的根节点下的子节点作为对象的字段，同名的节点作为数组
+func decodeXml(data []byte, v interface{}) error {
+	p, ok := v.(*interface{})
+	if !ok {
+		return xml.Unmarshal(data, v)
+	}
+
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			*p, err = decodeXmlElement(decoder, start, 1)
+			return err
+		}
+	}
+}
+
+var errXmlTooDeep = fmt.Errorf("xml: exceeded max depth")
+
+// depth 为节点的层数，超过 MaxJsonDepth 时返回错误
+func decodeXmlElement(decoder *xml.Decoder, start xml.StartElement, depth int) (interface{}, error) {
+	if Config.MaxJsonDepth > 0 && depth > Config.MaxJsonDepth {
+		return nil, errXmlTooDeep
+	}
+	children := map[string]interface{}{}
+	for _, attr := range start.Attr {
+		children[attr.Name.Local] = attr.Value
+	}
+	text := new(bytes.Buffer)
+	hasChildren := len(start.Attr) > 0
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			hasChildren = true
+			child, err := decodeXmlElement(decoder, t, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			name := t.Name.Local
+			if old, exists := children[name]; exists {
+				if list, ok := old.([]interface{}); ok {
+					children[name] = append(list, child)
+				} else {
+					children[name] = []interface{}{old, child}
+				}
+			} else {
+				children[name] = child
+			}
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			if hasChildren {
+				return children, nil
+			}
+			return text.String(), nil
+		}
+	}
+}
+
+func encodeXml(data interface{}) ([]byte, error) {
+	generic, err := makeGenericResult(data)
+	if err != nil {
+		return nil, err
+	}
+	buf := new(bytes.Buffer)
+	buf.WriteString(xml.Header)
+	writeXmlElement(buf, "result", generic)
+	return buf.Bytes(), nil
+}
+
+// 数组输出为多个同名的节点，顶层的数组使用 item 作为节点名
+func writeXmlElement(buf *bytes.Buffer, name string, data interface{}) {
+	open, end := makeXmlTag(name)
+	switch v := data.(type) {
+	case map[string]interface{}:
+		buf.WriteString(open + ">")
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if list, ok := v[k].([]interface{}); ok {
+				for _, item := range list {
+					writeXmlElement(buf, k, item)
+				}
+			} else {
+				writeXmlElement(buf, k, v[k])
+			}
+		}
+		buf.WriteString(end)
+	case []interface{}:
+		buf.WriteString(open + ">")
+		for _, item := range v {
+			writeXmlElement(buf, "item", item)
+		}
+		buf.WriteString(end)
+	case nil:
+		buf.WriteString(open + "/>")
+	default:
+		buf.WriteString(open + ">")
+		_ = xml.EscapeText(buf, []byte(u.String(v)))
+		buf.WriteString(end)
+	}
+}
+
+// 不能作为节点名的 key 输出为 <entry key="...">
+func makeXmlTag(name string) (string, string) {
+	if isXmlName(name) {
+		return "<" + name, "</" + name + ">"
+	}
+	key := new(bytes.Buffer)
+	_ = xml.EscapeText(key, []byte(name))
+	return `<entry key="` + key.String() + `"`, "</entry>"
+}
+
+func isXmlName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		if r == '_' || unicode.IsLetter(r) || (i > 0 && (r == '-' || r == '.' || unicode.IsDigit(r))) {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+func decodeMsgpack(data []byte, v interface{}) error {
+	value, _, err := readMsgpack(data, 0, 0)
+	if err != nil {
+		return err
+	}
+	if p, ok := v.(*interface{}); ok {
+		*p = value
+		return nil
+	}
+	if reflect.TypeOf(v).Kind() != reflect.Ptr {
+		return fmt.Errorf("msgpack: decode to non-pointer %s", reflect.TypeOf(v))
+	}
+	u.Convert(value, v)
+	return nil
+}
+
+func encodeMsgpack(data interface{}) ([]byte, error) {
+	generic, err := makeGenericResult(data)
+	if err != nil {
+		return nil, err
+	}
+	buf := new(bytes.Buffer)
+	err = writeMsgpack(buf, generic)
+	return buf.Bytes(), err
+}
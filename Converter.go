@@ -0,0 +1,340 @@
+package s
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ssgo/u"
+)
+
+// 需要使用转换器的字段，nested 为包含这类字段的结构体
+type fieldConverter struct {
+	index     int
+	key       string
+	name      string
+	anonymous bool
+	slice     bool
+	convert   func(raw interface{}) (reflect.Value, error)
+	nested    *typeConverter
+}
+
+type typeConverter struct {
+	fields []fieldConverter
+}
+
+var converters = map[reflect.Type]func(interface{}) (interface{}, error){}
+var typeConverters sync.Map
+
+// 注册转换器时加写锁，生成结构体转换器时加读锁，避免使用注册之前生成的缓存
+var convertersLock = sync.RWMutex{}
+
+var timeType = reflect.TypeOf(time.Time{})
+var durationType = reflect.TypeOf(time.Duration(0))
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+var jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// 注册参数类型的转换器，参数绑定时该类型（包括指针和数组）的字段使用转换器转换，返回错误时输出 400
+// 可以在服务启动后注册，之后的请求使用新的转换器
+func RegisterConverter(dataType reflect.Type, converter func(value interface{}) (interface{}, error)) {
+	for dataType.Kind() == reflect.Ptr {
+		dataType = dataType.Elem()
+	}
+	convertersLock.Lock()
+	defer convertersLock.Unlock()
+	converters[dataType] = converter
+	clearTypeConverters()
+}
+
+func resetConverters() {
+	convertersLock.Lock()
+	defer convertersLock.Unlock()
+	converters = map[reflect.Type]func(interface{}) (interface{}, error){}
+	clearTypeConverters()
+}
+
+// 清除缓存的结构体转换器，不替换 sync.Map 以免和并发的请求竞争，需要持有写锁
+func clearTypeConverters() {
+	typeConverters.Range(func(key, _ interface{}) bool {
+		typeConverters.Delete(key)
+		return true
+	})
+}
+
+// 转换参数到结构体，不能使用 u.Convert 转换的字段（time.Time、time.Duration、TextUnmarshaler、json.Unmarshaler、注册了转换器的类型）单独转换
+func convertInput(data map[string]interface{}, in interface{}) []FieldError {
+	v := reflect.ValueOf(in)
+	converter := getTypeConverter(v.Type())
+	if converter == nil {
+		u.Convert(data, in)
+		return nil
+	}
+	u.Convert(converter.strip(data), in)
+	errors := make([]FieldError, 0)
+	converter.apply(data, v.Elem(), "", &errors)
+	return errors
+}
+
+func getTypeConverter(t reflect.Type) *typeConverter {
+	convertersLock.RLock()
+	defer convertersLock.RUnlock()
+	return getTypeConverterWithParents(t, map[reflect.Type]bool{})
+}
+
+func getTypeConverterWithParents(t reflect.Type, parents map[reflect.Type]bool) *typeConverter {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || parents[t] {
+		return nil
+	}
+	key := namedFieldsKey{t, Config.FieldNaming}
+	if cached, ok := typeConverters.Load(key); ok {
+		return cached.(*typeConverter)
+	}
+
+	parents[t] = true
+	defer delete(parents, t)
+
+	converter := &typeConverter{fields: make([]fieldConverter, 0)}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+		fc := fieldConverter{index: i, key: field.Name, name: fieldErrorName(field), anonymous: field.Anonymous}
+		ft := field.Type
+		if ft.Kind() == reflect.Slice && ft.Elem().Kind() != reflect.Uint8 {
+			fc.slice = true
+			ft = ft.Elem()
+		}
+		if fc.convert = getValueConverter(ft); fc.convert != nil {
+			converter.fields = append(converter.fields, fc)
+			continue
+		}
+		if !fc.slice {
+			if fc.nested = getTypeConverterWithParents(ft, parents); fc.nested != nil {
+				converter.fields = append(converter.fields, fc)
+			}
+		}
+	}
+	if len(converter.fields) == 0 {
+		converter = nil
+	}
+	typeConverters.Store(key, converter)
+	return converter
+}
+
+// 获取类型的转换函数，返回的值与 t 的类型一致
+func getValueConverter(t reflect.Type) func(interface{}) (reflect.Value, error) {
+	baseType := t
+	for baseType.Kind() == reflect.Ptr {
+		baseType = baseType.Elem()
+	}
+	var convert func(interface{}) (reflect.Value, error)
+	if converter := converters[baseType]; converter != nil {
+		convert = func(raw interface{}) (reflect.Value, error) {
+			out, err := converter(raw)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			outValue := reflect.ValueOf(out)
+			for outValue.Kind() == reflect.Ptr && outValue.Type() != baseType && !outValue.IsNil() {
+				outValue = outValue.Elem()
+			}
+			if !outValue.IsValid() || !outValue.Type().ConvertibleTo(baseType) {
+				return reflect.Value{}, fmt.Errorf("converter returned %T", out)
+			}
+			return outValue.Convert(baseType), nil
+		}
+	} else if baseType == timeType {
+		convert = func(raw interface{}) (reflect.Value, error) {
+			tm, err := parseTime(raw)
+			return reflect.ValueOf(tm), err
+		}
+	} else if baseType == durationType {
+		convert = func(raw interface{}) (reflect.Value, error) {
+			d, err := parseDuration(raw)
+			return reflect.ValueOf(d), err
+		}
+	} else if reflect.PtrTo(baseType).Implements(textUnmarshalerType) {
+		convert = func(raw interface{}) (reflect.Value, error) {
+			out := reflect.New(baseType)
+			err := out.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(u.String(raw)))
+			return out.Elem(), err
+		}
+	} else if reflect.PtrTo(baseType).Implements(jsonUnmarshalerType) {
+		convert = func(raw interface{}) (reflect.Value, error) {
+			out := reflect.New(baseType)
+			data, err := json.Marshal(raw)
+			if err == nil {
+				err = out.Interface().(json.Unmarshaler).UnmarshalJSON(data)
+			}
+			return out.Elem(), err
+		}
+	}
+	if convert == nil || baseType == t {
+		return convert
+	}
+	// 指针类型的字段
+	return func(raw interface{}) (reflect.Value, error) {
+		v, err := convert(raw)
+		if err != nil {
+			return v, err
+		}
+		for current := t; current.Kind() == reflect.Ptr; current = current.Elem() {
+			p := reflect.New(v.Type())
+			p.Elem().Set(v)
+			v = p
+		}
+		return v, nil
+	}
+}
+
+// 去掉需要单独转换的字段，用于 u.Convert
+func (converter *typeConverter) strip(data map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(data))
+	for k, v := range data {
+		out[k] = v
+	}
+	for _, fc := range converter.fields {
+		if fc.anonymous && fc.nested != nil {
+			out = fc.nested.strip(out)
+			continue
+		}
+		for k, v := range out {
+			if !strings.EqualFold(k, fc.key) {
+				continue
+			}
+			if fc.convert != nil {
+				delete(out, k)
+			} else if m, ok := v.(map[string]interface{}); ok {
+				out[k] = fc.nested.strip(m)
+			}
+		}
+	}
+	return out
+}
+
+// 转换并设置字段，转换失败的字段记录到 errors
+func (converter *typeConverter) apply(data map[string]interface{}, v reflect.Value, prefix string, errors *[]FieldError) {
+	for _, fc := range converter.fields {
+		field := v.Field(fc.index)
+		if fc.anonymous && fc.nested != nil {
+			if target := settableStruct(field); target.IsValid() {
+				fc.nested.apply(data, target, prefix, errors)
+			}
+			continue
+		}
+		raw, ok := lookupArg(data, fc.key)
+		if !ok || raw == nil {
+			continue
+		}
+		name := prefix + fc.name
+		if fc.convert == nil {
+			if m, ok := raw.(map[string]interface{}); ok {
+				if target := settableStruct(field); target.IsValid() {
+					fc.nested.apply(m, target, name+".", errors)
+				}
+			}
+			continue
+		}
+		if !fc.slice {
+			if s, isString := raw.(string); isString && s == "" {
+				continue
+			}
+			out, err := fc.convert(raw)
+			if err != nil {
+				*errors = append(*errors, FieldError{Field: name, Rule: "type", Message: "is invalid: " + err.Error()})
+				continue
+			}
+			field.Set(out)
+			continue
+		}
+		items := reflect.ValueOf(raw)
+		if items.Kind() != reflect.Slice {
+			items = reflect.ValueOf([]interface{}{raw})
+		}
+		list := reflect.MakeSlice(field.Type(), 0, items.Len())
+		for i := 0; i < items.Len(); i++ {
+			out, err := fc.convert(items.Index(i).Interface())
+			if err != nil {
+				*errors = append(*errors, FieldError{Field: name + "." + strconv.Itoa(i), Rule: "type", Message: "is invalid: " + err.Error()})
+				continue
+			}
+			list = reflect.Append(list, out)
+		}
+		field.Set(list)
+	}
+}
+
+// 结构体或结构体指针的字段，指针为空时创建
+func settableStruct(field reflect.Value) reflect.Value {
+	for field.Kind() == reflect.Ptr {
+		if field.IsNil() {
+			if !field.CanSet() {
+				return reflect.Value{}
+			}
+			field.Set(reflect.New(field.Type().Elem()))
+		}
+		field = field.Elem()
+	}
+	if field.Kind() != reflect.Struct {
+		return reflect.Value{}
+	}
+	return field
+}
+
+// 支持 RFC3339、2006-01-02 15:04:05、2006-01-02 和时间戳（秒或毫秒）
+func parseTime(raw interface{}) (time.Time, error) {
+	if tm, ok := raw.(time.Time); ok {
+		return tm, nil
+	}
+	str := strings.TrimSpace(u.String(raw))
+	if n, err := strconv.ParseFloat(str, 64); err == nil {
+		if n > 1e12 {
+			return time.Unix(0, int64(n*1e6)), nil
+		}
+		return time.Unix(0, int64(n*1e9)), nil
+	}
+	if tm, err := time.Parse(time.RFC3339Nano, str); err == nil {
+		return tm, nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02 15:04", "2006-01-02"} {
+		if tm, err := time.ParseInLocation(layout, str, time.Local); err == nil {
+			return tm, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unknown time format %s", str)
+}
+
+// 支持 1h30m 格式，数字为毫秒
+func parseDuration(raw interface{}) (time.Duration, error) {
+	str := strings.TrimSpace(u.String(raw))
+	if n, err := strconv.ParseFloat(str, 64); err == nil {
+		return time.Duration(n * float64(time.Millisecond)), nil
+	}
+	return time.ParseDuration(str)
+}
+
+// 合并转换和校验的错误，转换失败的字段不再输出校验的错误
+func mergeFieldErrors(convertErrors, validateErrors []FieldError) []FieldError {
+	if len(convertErrors) == 0 {
+		return validateErrors
+	}
+	failed := map[string]bool{}
+	for _, err := range convertErrors {
+		failed[err.Field] = true
+	}
+	for _, err := range validateErrors {
+		if !failed[err.Field] {
+			convertErrors = append(convertErrors, err)
+		}
+	}
+	return convertErrors
+}
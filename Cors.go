@@ -0,0 +1,221 @@
+package s
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// 跨域访问配置，多个值使用逗号分隔
+// AllowOrigins 支持 * 通配（例如 https://*.abc.com），以 ^ 开头的作为正则表达式
+// AllowMethods 未设置时允许路径上注册的全部方法，AllowHeaders 未设置时允许请求的全部头
+type CorsConfig struct {
+	AllowOrigins     string
+	AllowMethods     string
+	AllowHeaders     string
+	ExposeHeaders    string
+	AllowCredentials bool
+	MaxAge           int
+}
+
+type corsPolicy struct {
+	config         CorsConfig
+	anyOrigin      bool
+	origins        map[string]bool
+	originMatchers []*regexp.Regexp
+	methods        map[string]bool
+	headers        map[string]bool
+	anyHeader      bool
+}
+
+var globalCors *corsPolicy
+
+func makeCorsPolicy(config CorsConfig) *corsPolicy {
+	policy := &corsPolicy{config: config, origins: map[string]bool{}, methods: map[string]bool{}, headers: map[string]bool{}}
+	for _, origin := range splitCorsList(config.AllowOrigins) {
+		if origin == "*" {
+			policy.anyOrigin = true
+			continue
+		}
+		expr := ""
+		if origin[0] == '^' {
+			expr = origin
+		} else if strings.ContainsRune(origin, '*') {
+			expr = "^" + strings.Replace(regexp.QuoteMeta(origin), `\*`, `[^/]*`, -1) + "$"
+		} else {
+			policy.origins[strings.ToLower(origin)] = true
+			continue
+		}
+		matcher, err := regexp.Compile(expr)
+		if err != nil {
+			logError(err.Error(), "origin", origin)
+			continue
+		}
+		policy.originMatchers = append(policy.originMatchers, matcher)
+	}
+	// 允许任意来源时不能携带凭证，否则任何网站都可以读取用户的数据
+	if policy.anyOrigin && config.AllowCredentials {
+		logError("cors: AllowCredentials is ignored when AllowOrigins is *", "allowOrigins", config.AllowOrigins)
+		policy.config.AllowCredentials = false
+	}
+	for _, method := range splitCorsList(config.AllowMethods) {
+		policy.methods[strings.ToUpper(method)] = true
+	}
+	for _, header := range splitCorsList(config.AllowHeaders) {
+		if header == "*" {
+			policy.anyHeader = true
+		}
+		policy.headers[strings.ToLower(header)] = true
+	}
+	if len(policy.headers) == 0 {
+		policy.anyHeader = true
+	}
+	return policy
+}
+
+func splitCorsList(str string) []string {
+	list := make([]string, 0)
+	for _, v := range strings.Split(str, ",") {
+		v = strings.TrimSpace(v)
+		if v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// 设置全局的跨域访问配置，与 service 配置中的 cors 相同
+func SetCors(config CorsConfig) {
+	Config.Cors = config
+	globalCors = makeCorsPolicy(config)
+}
+
+// 设置分组的跨域访问配置，覆盖全局配置
+func (g *ServiceGroup) SetCors(config CorsConfig) {
+	g.cors = makeCorsPolicy(config)
+}
+
+// 设置服务的跨域访问配置，覆盖分组和全局配置
+func (sr *ServiceRegister) SetCors(config CorsConfig) *ServiceRegister {
+	if sr.service != nil {
+		sr.service.cors = makeCorsPolicy(config)
+	}
+	return sr
+}
+
+// 服务使用的跨域访问配置，优先级：服务 > 分组（由内到外） > 全局
+func getCorsPolicy(s *webServiceType) *corsPolicy {
+	if s.cors != nil {
+		return s.cors
+	}
+	for g := s.group; g != nil; g = g.parent {
+		if g.cors != nil {
+			return g.cors
+		}
+	}
+	return globalCors
+}
+
+func (policy *corsPolicy) allowOrigin(origin string) bool {
+	if policy.anyOrigin || policy.origins[strings.ToLower(origin)] {
+		return true
+	}
+	for _, matcher := range policy.originMatchers {
+		if matcher.MatchString(origin) {
+			return true
+		}
+	}
+	return false
+}
+
+func (policy *corsPolicy) setOriginHeaders(origin string, header http.Header) {
+	if policy.anyOrigin {
+		header.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		header.Set("Access-Control-Allow-Origin", origin)
+		header.Add("Vary", "Origin")
+	}
+	if policy.config.AllowCredentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+// 为跨域的请求添加响应头
+func processCors(s *webServiceType, request *http.Request, response http.ResponseWriter) {
+	origin := request.Header.Get("Origin")
+	if origin == "" {
+		return
+	}
+	policy := getCorsPolicy(s)
+	if policy == nil || !policy.allowOrigin(origin) {
+		return
+	}
+	policy.setOriginHeaders(origin, response.Header())
+	if policy.config.ExposeHeaders != "" {
+		response.Header().Set("Access-Control-Expose-Headers", strings.Join(splitCorsList(policy.config.ExposeHeaders), ", "))
+	}
+}
+
+// 处理跨域预检请求，路径上没有配置跨域访问时返回 false 交给后续处理
+func processPreflight(ctx *Context) bool {
+	request := ctx.Request
+	origin := request.Header.Get("Origin")
+	requestMethod := strings.ToUpper(request.Header.Get("Access-Control-Request-Method"))
+	s, _ := matchRoute(ctx.host, requestMethod, ctx.requestPath, map[string]interface{}{})
+	if s == nil {
+		return false
+	}
+	policy := getCorsPolicy(s)
+	if policy == nil {
+		return false
+	}
+
+	allowed := policy.allowOrigin(origin)
+	allowMethods := make([]string, 0)
+	if allowed {
+		if len(policy.methods) > 0 {
+			allowed = policy.methods[requestMethod]
+			for _, method := range splitCorsList(policy.config.AllowMethods) {
+				allowMethods = append(allowMethods, strings.ToUpper(method))
+			}
+		} else {
+			allowMethods = allowedMethods(ctx.host, ctx.requestPath)
+		}
+	}
+	requestHeaders := splitCorsList(request.Header.Get("Access-Control-Request-Headers"))
+	if allowed && !policy.anyHeader {
+		for _, header := range requestHeaders {
+			if !policy.headers[strings.ToLower(header)] {
+				allowed = false
+				break
+			}
+		}
+	}
+
+	header := ctx.Response.Header()
+	header.Add("Vary", "Access-Control-Request-Method")
+	header.Add("Vary", "Access-Control-Request-Headers")
+	args := map[string]interface{}{}
+	if !allowed {
+		ctx.Response.WriteHeader(403)
+		writeLog(ctx.Logger, "REJECT", nil, 0, request, ctx.Response, &args, &ctx.logHeaders, &ctx.StartTime, 0, nil)
+		return true
+	}
+
+	policy.setOriginHeaders(origin, header)
+	header.Set("Access-Control-Allow-Methods", strings.Join(allowMethods, ", "))
+	if len(requestHeaders) > 0 {
+		if policy.anyHeader {
+			header.Set("Access-Control-Allow-Headers", strings.Join(requestHeaders, ", "))
+		} else {
+			header.Set("Access-Control-Allow-Headers", strings.Join(splitCorsList(policy.config.AllowHeaders), ", "))
+		}
+	}
+	if policy.config.MaxAge > 0 {
+		header.Set("Access-Control-Max-Age", strconv.Itoa(policy.config.MaxAge))
+	}
+	ctx.Response.WriteHeader(204)
+	writeLog(ctx.Logger, "ACCESS", nil, 0, request, ctx.Response, &args, &ctx.logHeaders, &ctx.StartTime, 0, nil)
+	return true
+}
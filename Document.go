@@ -9,6 +9,7 @@ import (
 	"os"
 	"os/user"
 	"reflect"
+	"regexp"
 	"strings"
 
 	"github.com/ssgo/u"
@@ -16,6 +17,7 @@ import (
 
 type Api struct {
 	Type      string
+	Host      string
 	Path      string
 	AuthLevel int
 	Priority  int
@@ -44,6 +46,15 @@ func MakeDocument() []Api {
 		out = append(out, api)
 	}
 
+	for _, a := range hostRewrites {
+		api := Api{
+			Type: "Rewrite",
+			Host: a.host.pattern,
+			Path: a.fromPath + " -> " + a.toPath,
+		}
+		out = append(out, api)
+	}
+
 	for _, a := range proxies {
 		api := Api{
 			Type: "Proxy",
@@ -60,28 +71,19 @@ func MakeDocument() []Api {
 		out = append(out, api)
 	}
 
-	for _, a := range webServices {
+	for _, a := range hostProxies {
 		api := Api{
-			Type:      "Web",
-			Path:      a.path,
-			AuthLevel: a.authLevel,
-			Priority:  a.priority,
-			Method:    a.method,
-			In:        "",
-			Out:       "",
-		}
-		if a.inType != nil {
-			api.In = getType(a.inType)
-		}
-		if a.funcType.NumOut() > 0 {
-			api.Out = getType(a.funcType.Out(0))
+			Type: "Proxy",
+			Host: a.host.pattern,
+			Path: a.fromPath + " -> " + a.toApp + ":" + a.toPath,
 		}
 		out = append(out, api)
 	}
 
-	for _, a := range regexWebServices {
+	for _, a := range webServices {
 		api := Api{
 			Type:      "Web",
+			Host:      a.host,
 			Path:      a.path,
 			AuthLevel: a.authLevel,
 			Priority:  a.priority,
@@ -89,24 +91,17 @@ func MakeDocument() []Api {
 			In:        "",
 			Out:       "",
 		}
-
 		if a.inType != nil {
 			api.In = getType(a.inType)
+			markPathArgs(api.In, a.path)
 		}
-		if a.funcType.NumOut() > 0 {
-			api.Out = getType(a.funcType.Out(0))
+		if a.funcType.NumOut() > 0 && a.errorIndex != 0 {
+			api.Out = getOutputType(a.funcType.Out(0))
 		}
 		out = append(out, api)
 	}
 
-	allWebsocketServices := make([]*websocketServiceType, 0)
 	for _, a := range websocketServices {
-		allWebsocketServices = append(allWebsocketServices, a)
-	}
-	for _, a := range regexWebsocketServices {
-		allWebsocketServices = append(allWebsocketServices, a)
-	}
-	for _, a := range allWebsocketServices {
 		api := Api{
 			Type:      "WebSocket",
 			Path:      a.path,
@@ -119,7 +114,7 @@ func MakeDocument() []Api {
 			api.In = getType(a.openInType)
 		}
 		if a.openFuncType.NumOut() > 0 {
-			api.Out = getType(a.openFuncType.Out(0))
+			api.Out = getOutputType(a.openFuncType.Out(0))
 		}
 		out = append(out, api)
 
@@ -136,7 +131,7 @@ func MakeDocument() []Api {
 				api.In = getType(action.inType)
 			}
 			if action.funcType.NumOut() > 0 {
-				api.Out = getType(action.funcType.Out(0))
+				api.Out = getOutputType(action.funcType.Out(0))
 			}
 			out = append(out, api)
 		}
@@ -238,14 +233,24 @@ func getType(t reflect.Type) interface{} {
 	for t.Kind() == reflect.Ptr {
 		t = t.Elem()
 	}
+	if t == uploadFileType {
+		return "file"
+	}
 	switch t.Kind() {
 	case reflect.Struct:
 		outs := Map{}
+		validator := getValidator(t)
 		for i := 0; i < t.NumField(); i++ {
-			if t.Field(i).Tag != "" && reflect.ValueOf(outs[t.Field(i).Name]).Kind() == reflect.String {
-				outs[t.Field(i).Name] = fmt.Sprint(outs[t.Field(i).Name].(string), " ", t.Field(i).Tag)
-			} else {
-				outs[t.Field(i).Name] = getType(t.Field(i).Type)
+			outs[t.Field(i).Name] = getType(t.Field(i).Type)
+			// 在类型后面显示参数的来源和校验规则
+			if typeName, ok := outs[t.Field(i).Name].(string); ok {
+				if source, key := parseBindingTag(t.Field(i).Tag.Get("s")); source != "" {
+					typeName += " in:" + source + u.StringIf(key != "", "("+key+")", "")
+				}
+				if rules := validator.rulesText(i); rules != "" {
+					typeName += " " + rules
+				}
+				outs[t.Field(i).Name] = typeName
 			}
 		}
 		return outs
@@ -260,6 +265,56 @@ func getType(t reflect.Type) interface{} {
 	}
 }
 
+// 输出的类型，字段名与输出的 JSON 一致
+func getOutputType(t reflect.Type) interface{} {
+	if t == nil {
+		return ""
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Implements(jsonMarshalerType) || t.Implements(textMarshalerType) {
+		return t.String()
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		outs := Map{}
+		for _, f := range getNamedFields(t) {
+			outs[f.name] = getOutputType(t.FieldByIndex(f.index).Type)
+		}
+		return outs
+	case reflect.Map:
+		return fmt.Sprintf("map[%s]%s", getOutputType(t.Key()), getOutputType(t.Elem()))
+	case reflect.Slice:
+		return fmt.Sprint("[]", getOutputType(t.Elem()))
+	case reflect.Interface:
+		return "*"
+	default:
+		return t.String()
+	}
+}
+
+var pathArgMatcher = regexp.MustCompile(`\{(\w+)`)
+
+// 没有指定来源的字段与路径中的参数同名时，值来自路径
+func markPathArgs(in interface{}, path string) {
+	fields, ok := in.(Map)
+	if !ok {
+		return
+	}
+	for _, m := range pathArgMatcher.FindAllStringSubmatch(path, -1) {
+		for name, desc := range fields {
+			if typeName, ok := desc.(string); ok && strings.EqualFold(name, m[1]) && !strings.Contains(typeName, " in:") {
+				if pos := strings.IndexByte(typeName, ' '); pos > 0 {
+					fields[name] = typeName[0:pos] + " in:path" + typeName[pos:]
+				} else {
+					fields[name] = typeName + " in:path"
+				}
+			}
+		}
+	}
+}
+
 func isMap(arg interface{}) bool {
 	if arg == nil {
 		return false
@@ -0,0 +1,132 @@
+package s
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/websocket"
+	"github.com/ssgo/u"
+)
+
+// 服务分组，组内的服务共享路径前缀和授权等级，分组的过滤器和身份认证只作用于组内（包括子分组）的服务
+type ServiceGroup struct {
+	parent      *ServiceGroup
+	prefix      string
+	authLevel   int
+	inFilters   []func(*map[string]interface{}, *http.Request, *http.ResponseWriter) interface{}
+	outFilters  []func(*map[string]interface{}, *http.Request, *http.ResponseWriter, interface{}) (interface{}, bool)
+	authChecker func(int, *string, *map[string]interface{}, *http.Request) bool
+	middlewares []func(*Context, func())
+	cors        *corsPolicy
+}
+
+// 创建服务分组，组内服务的授权等级不低于 authLevel
+func Group(prefix string, authLevel int) *ServiceGroup {
+	return &ServiceGroup{prefix: fixGroupPrefix(prefix), authLevel: authLevel}
+}
+
+// 创建子分组，路径前缀和授权等级在上级分组的基础上叠加
+func (g *ServiceGroup) Group(prefix string, authLevel int) *ServiceGroup {
+	return &ServiceGroup{parent: g, prefix: g.prefix + fixGroupPrefix(prefix), authLevel: g.makeAuthLevel(authLevel)}
+}
+
+func fixGroupPrefix(prefix string) string {
+	prefix = strings.TrimRight(prefix, "/")
+	if prefix != "" && prefix[0] != '/' {
+		prefix = "/" + prefix
+	}
+	return prefix
+}
+
+// 获取分组的路径前缀
+func (g *ServiceGroup) Prefix() string {
+	return g.prefix
+}
+
+func (g *ServiceGroup) makePath(path string) string {
+	if path == "" || path == "/" {
+		return u.StringIf(g.prefix == "", "/", g.prefix)
+	}
+	if path[0] != '/' {
+		path = "/" + path
+	}
+	return g.prefix + path
+}
+
+func (g *ServiceGroup) makeAuthLevel(authLevel int) int {
+	if g.authLevel > authLevel {
+		return g.authLevel
+	}
+	return authLevel
+}
+
+// 在分组中注册服务
+func (g *ServiceGroup) Register(authLevel int, path string, serviceFunc interface{}) *ServiceRegister {
+	return g.RestfulWithPriority(authLevel, 0, "", path, serviceFunc)
+}
+
+// 在分组中注册服务
+func (g *ServiceGroup) Restful(authLevel int, method, path string, serviceFunc interface{}) *ServiceRegister {
+	return g.RestfulWithPriority(authLevel, 0, method, path, serviceFunc)
+}
+
+// 在分组中注册服务
+func (g *ServiceGroup) RegisterWithPriority(authLevel, priority int, path string, serviceFunc interface{}) *ServiceRegister {
+	return g.RestfulWithPriority(authLevel, priority, "", path, serviceFunc)
+}
+
+// 在分组中注册服务
+func (g *ServiceGroup) RestfulWithPriority(authLevel, priority int, method, path string, serviceFunc interface{}) *ServiceRegister {
+	return restful(g, "", g.makeAuthLevel(authLevel), priority, method, g.makePath(path), serviceFunc)
+}
+
+// 在分组中注册Websocket服务
+func (g *ServiceGroup) RegisterWebsocket(authLevel int, path string, updater *websocket.Upgrader,
+	onOpen interface{},
+	onClose interface{},
+	decoder func(data interface{}) (action string, request *map[string]interface{}, err error),
+	encoder func(action string, data interface{}) interface{}) *ActionRegister {
+	return g.RegisterWebsocketWithPriority(authLevel, 0, path, updater, onOpen, onClose, decoder, encoder)
+}
+
+// 在分组中注册Websocket服务
+func (g *ServiceGroup) RegisterWebsocketWithPriority(authLevel, priority int, path string, updater *websocket.Upgrader,
+	onOpen interface{},
+	onClose interface{},
+	decoder func(data interface{}) (action string, request *map[string]interface{}, err error),
+	encoder func(action string, data interface{}) interface{}) *ActionRegister {
+	return registerWebsocket(g, g.makeAuthLevel(authLevel), priority, g.makePath(path), updater, onOpen, onClose, decoder, encoder)
+}
+
+// 设置分组的前置过滤器，在全局前置过滤器之后执行
+func (g *ServiceGroup) SetInFilter(filter func(in *map[string]interface{}, request *http.Request, response *http.ResponseWriter) (out interface{})) {
+	g.inFilters = append(g.inFilters, filter)
+}
+
+// 设置分组的后置过滤器，在全局后置过滤器之后执行
+func (g *ServiceGroup) SetOutFilter(filter func(in *map[string]interface{}, request *http.Request, response *http.ResponseWriter, out interface{}) (newOut interface{}, isOver bool)) {
+	g.outFilters = append(g.outFilters, filter)
+}
+
+// 设置分组的身份认证，未设置时使用上级分组或全局的身份认证
+func (g *ServiceGroup) SetAuthChecker(authChecker func(authLevel int, url *string, in *map[string]interface{}, request *http.Request) bool) {
+	g.authChecker = authChecker
+}
+
+// 从最外层分组到当前分组的链
+func (g *ServiceGroup) chain() []*ServiceGroup {
+	groups := make([]*ServiceGroup, 0)
+	for current := g; current != nil; current = current.parent {
+		groups = append([]*ServiceGroup{current}, groups...)
+	}
+	return groups
+}
+
+func (g *ServiceGroup) getAuthChecker() func(int, *string, *map[string]interface{}, *http.Request) bool {
+	for current := g; current != nil; current = current.parent {
+		if current.authChecker != nil {
+			return current.authChecker
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package s
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+)
+
+// 服务返回的错误，输出为 {"status": 404, "message": "..."}，有 Data 时同时输出 data
+type HTTPError struct {
+	Status  int
+	Message string
+	Data    interface{} `json:",omitempty"`
+	cause   error
+}
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+var errorMapper func(error) *HTTPError
+
+// 创建一个指定状态码的错误，message 为空时使用状态码对应的文字
+func NewHTTPError(status int, message string) *HTTPError {
+	if message == "" {
+		message = http.StatusText(status)
+	}
+	return &HTTPError{Status: status, Message: message}
+}
+
+func (err *HTTPError) Error() string {
+	return err.Message
+}
+
+// 设置错误的转换，服务返回的 error 先使用 mapper 转换，返回 nil 时使用默认的规则
+// 默认规则：HTTPError（包括使用 Unwrap 包装的）按自身的状态码输出，context 超时输出 504，context 取消输出 503，其他错误输出 500
+func SetErrorMapper(mapper func(err error) *HTTPError) {
+	errorMapper = mapper
+}
+
+func makeHTTPError(err error) *HTTPError {
+	if errorMapper != nil {
+		if httpErr := errorMapper(err); httpErr != nil {
+			return withCause(httpErr, err)
+		}
+	}
+	for current := err; current != nil; {
+		if httpErr, ok := current.(*HTTPError); ok {
+			return withCause(httpErr, err)
+		}
+		if current == context.DeadlineExceeded || current == context.Canceled {
+			return makeContextHTTPError(current, err)
+		}
+		wrapper, ok := current.(interface{ Unwrap() error })
+		if !ok {
+			break
+		}
+		current = wrapper.Unwrap()
+	}
+	return &HTTPError{Status: http.StatusInternalServerError, Message: http.StatusText(http.StatusInternalServerError), cause: err}
+}
+
+func withCause(httpErr *HTTPError, err error) *HTTPError {
+	out := *httpErr
+	if out.Status == 0 {
+		out.Status = http.StatusInternalServerError
+	}
+	if out.Message == "" {
+		out.Message = http.StatusText(out.Status)
+	}
+	out.cause = err
+	return &out
+}
+
+// 用于日志的错误信息，包含原始错误
+func (err *HTTPError) logMessage() string {
+	if err.cause != nil && err.cause.Error() != err.Message {
+		return err.cause.Error()
+	}
+	return err.Message
+}
@@ -0,0 +1,137 @@
+package s
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ssgo/standard"
+	"github.com/ssgo/u"
+)
+
+// 域名匹配规则，例如 api.abc.com、*.abc.com、{tenant}.abc.com
+// * 在最左侧时匹配一级或多级子域名，在其他位置匹配一级；{name} 匹配一级并作为参数 name，{name*} 匹配多级
+type hostMatcher struct {
+	pattern    string
+	matcher    *regexp.Regexp
+	names      []string
+	literalLen int
+}
+
+// 使用域名区分的路由树
+type hostRouter struct {
+	host   *hostMatcher
+	router *routeNode
+}
+
+func makeHostMatcher(pattern string) (*hostMatcher, error) {
+	pattern = strings.ToLower(strings.TrimSpace(pattern))
+	if pattern == "" {
+		return nil, fmt.Errorf("empty host pattern")
+	}
+	hm := &hostMatcher{pattern: pattern}
+	labels := strings.Split(pattern, ".")
+	exprs := make([]string, len(labels))
+	isExact := true
+	for i, label := range labels {
+		if label == "*" {
+			isExact = false
+			exprs[i] = u.StringIf(i == 0, `[^.]+(?:\.[^.]+)*`, `[^.]+`)
+		} else if len(label) > 2 && label[0] == '{' && label[len(label)-1] == '}' {
+			isExact = false
+			name := label[1 : len(label)-1]
+			labelExpr := `[^.]+`
+			if strings.HasSuffix(name, "*") {
+				name = name[0 : len(name)-1]
+				labelExpr = `[^.]+(?:\.[^.]+)*`
+			}
+			hm.names = append(hm.names, name)
+			exprs[i] = fmt.Sprintf("(%s)", labelExpr)
+		} else {
+			hm.literalLen += len(label)
+			exprs[i] = regexp.QuoteMeta(label)
+		}
+	}
+	if !isExact {
+		matcher, err := regexp.Compile("^" + strings.Join(exprs, `\.`) + "$")
+		if err != nil {
+			return nil, err
+		}
+		hm.matcher = matcher
+	}
+	return hm, nil
+}
+
+// 匹配域名，匹配成功时返回域名中的参数
+func (hm *hostMatcher) match(host string) (map[string]string, bool) {
+	if hm.matcher == nil {
+		return nil, host == hm.pattern
+	}
+	finds := hm.matcher.FindStringSubmatch(host)
+	if finds == nil {
+		return nil, false
+	}
+	args := make(map[string]string, len(hm.names))
+	for i, name := range hm.names {
+		args[name] = finds[i+1]
+	}
+	return args, true
+}
+
+// 优先级：完整域名 > 固定部分多的规则 > 参数少的规则
+func hostMatcherLess(a, b *hostMatcher) bool {
+	if (a.matcher == nil) != (b.matcher == nil) {
+		return a.matcher == nil
+	}
+	if a.literalLen != b.literalLen {
+		return a.literalLen > b.literalLen
+	}
+	if len(a.names) != len(b.names) {
+		return len(a.names) < len(b.names)
+	}
+	return a.pattern < b.pattern
+}
+
+// 请求的域名，使用 X-Host 中的值，不包含端口
+func getRequestHost(request *http.Request) string {
+	host := request.Header.Get(standard.DiscoverHeaderHost)
+	if host == "" {
+		host = request.Host
+	}
+	if pos := strings.LastIndexByte(host, ':'); pos != -1 && !strings.HasSuffix(host, "]") {
+		host = host[0:pos]
+	}
+	return strings.ToLower(host)
+}
+
+// 替换 {name} 为域名中的参数
+func replaceHostArgs(str string, hostArgs map[string]string) string {
+	for k, v := range hostArgs {
+		str = strings.Replace(str, "{"+k+"}", v, -1)
+	}
+	return str
+}
+
+// 获取域名对应的路由树，不存在时创建
+func (table *routeTable) getHostRouter(host string) (*routeNode, error) {
+	if host == "" {
+		return table.router, nil
+	}
+	hm, err := makeHostMatcher(host)
+	if err != nil {
+		return nil, err
+	}
+	for _, hr := range table.hostRouters {
+		if hr.host.pattern == hm.pattern {
+			return hr.router, nil
+		}
+	}
+	hr := &hostRouter{host: hm, router: newRouteNode("")}
+	table.hostRouters = append(table.hostRouters, hr)
+	sort.SliceStable(table.hostRouters, func(i, j int) bool {
+		return hostMatcherLess(table.hostRouters[i].host, table.hostRouters[j].host)
+	})
+	return hr.router, nil
+}
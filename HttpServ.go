@@ -1,8 +1,8 @@
 package s
 
 import (
+	"bytes"
 	"compress/gzip"
-	"encoding/json"
 	"fmt"
 	"github.com/ssgo/discover"
 	"github.com/ssgo/log"
@@ -25,6 +25,16 @@ type Response struct {
 	ProxyHeader *http.Header
 }
 
+// 获取实际输出的 ResponseWriter
+func (response *Response) Writer() http.ResponseWriter {
+	return response.writer
+}
+
+// 替换实际输出的 ResponseWriter，可以在中间件中包装输出
+func (response *Response) SetWriter(writer http.ResponseWriter) {
+	response.writer = writer
+}
+
 func (response *Response) Header() http.Header {
 	if response.ProxyHeader != nil {
 		return *response.ProxyHeader
@@ -85,11 +95,12 @@ func (rh *routeHandler) Stop() {
 	for _, conn := range rh.wsConns {
 		_ = conn.Close()
 	}
+	closeSSESenders()
 }
 
 func (rh *routeHandler) Wait() {
 	for i := 0; i < 25; i++ {
-		if rh.webRequestingNum == 0 && len(rh.wsConns) == 0 {
+		if rh.webRequestingNum == 0 && len(rh.wsConns) == 0 && countSSESenders() == 0 {
 			break
 		}
 		time.Sleep(time.Millisecond * 200)
@@ -98,7 +109,6 @@ func (rh *routeHandler) Wait() {
 
 func (rh *routeHandler) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
 	var myResponse = &Response{writer: writer, status: 200}
-	var response http.ResponseWriter = myResponse
 	startTime := time.Now()
 
 	// 产生 X-Request-ID
@@ -146,7 +156,7 @@ func (rh *routeHandler) ServeHTTP(writer http.ResponseWriter, request *http.Requ
 				newSessionid = sessionCreator()
 			}
 			request.Header.Set(sessionKey, newSessionid)
-			response.Header().Set(sessionKey, newSessionid)
+			myResponse.Header().Set(sessionKey, newSessionid)
 		}
 		// 为了在服务间调用时续传 SessionId
 		request.Header.Set(standard.DiscoverHeaderSessionId, request.Header.Get(sessionKey))
@@ -172,14 +182,55 @@ func (rh *routeHandler) ServeHTTP(writer http.ResponseWriter, request *http.Requ
 
 	requestLogger := log.New(requestId)
 
+	// 请求中的注入对象保存在 context 中，请求结束后自动释放
+	request = withInjectScope(request)
+	ctx := &Context{
+		Request:    request,
+		Response:   myResponse,
+		Logger:     requestLogger,
+		StartTime:  startTime,
+		writer:     myResponse,
+		logHeaders: logHeaders,
+		host:       getRequestHost(request),
+	}
+
+	defer func() {
+		if err := recover(); err != nil {
+			var out interface{}
+			if errorHandle != nil {
+				out = errorHandle(err, request, &ctx.writer)
+			} else {
+				myResponse.WriteHeader(ResponseCodePanicError)
+				out = ""
+			}
+
+			logError(u.String(err))
+			writeLog(requestLogger, "PANIC", out, myResponse.outLen, request, myResponse, &ctx.Args, &ctx.logHeaders, &ctx.StartTime, ctx.authLevel, Map{
+				"error": err,
+			})
+		}
+	}()
+
+	// 全局中间件包裹整个处理过程
+	runMiddlewares(middlewares, ctx, func() {
+		processRequest(ctx)
+	})
+}
+
+// 处理 Rewrite、Proxy、静态文件，匹配服务并解析参数后交给服务处理
+func processRequest(ctx *Context) {
+	request := ctx.Request
+	myResponse := ctx.Response
+	requestLogger := ctx.Logger
+
 	// 处理 Rewrite，如果是外部转发，直接结束请求
-	finished := processRewrite(request, myResponse, &logHeaders, &startTime, requestLogger)
+	finished := processRewrite(request, myResponse, &ctx.logHeaders, &ctx.StartTime, requestLogger)
 	if finished {
 		return
 	}
 
 	// 处理 ProxyBy
-	finished = processProxy(request, myResponse, &logHeaders, &startTime, requestLogger)
+	finished = processProxy(request, myResponse, &ctx.logHeaders, &ctx.StartTime, requestLogger)
 	if finished {
 		return
 	}
@@ -191,77 +242,49 @@ func (rh *routeHandler) ServeHTTP(writer http.ResponseWriter, request *http.Requ
 	} else {
 		requestPath = request.RequestURI
 	}
+	ctx.requestPath = requestPath
 
 	// 处理静态文件
-	if processStatic(requestPath, request, myResponse, &logHeaders, &startTime, requestLogger) {
+	if processStatic(requestPath, request, myResponse, &ctx.logHeaders, &ctx.StartTime, requestLogger) {
 		return
 	}
 
-	args := make(map[string]interface{})
-
-	// 先看缓存中是否有 Service
-	var s *webServiceType
-	var ws *websocketServiceType
-	s = webServices[request.Method+requestPath]
-	if s == nil {
-		s = webServices[requestPath]
-		if s == nil {
-			ws = websocketServices[requestPath]
+	// 处理跨域预检请求
+	if request.Method == "OPTIONS" && request.Header.Get("Origin") != "" && request.Header.Get("Access-Control-Request-Method") != "" {
+		if processPreflight(ctx) {
+			return
 		}
 	}
 
-	// 未匹配到缓存，尝试匹配新的 Service
-	if s == nil && ws == nil {
-		//for _, tmpS := range regexWebServices {
-		maxRegexWebServicesKey := len(regexWebServices) - 1
-		for i := maxRegexWebServicesKey; i >= 0; i-- {
-			tmpS := regexWebServices[i]
-			if tmpS.method != "" && tmpS.method != request.Method {
-				continue
-			}
-			finds := tmpS.pathMatcher.FindAllStringSubmatch(requestPath, 20)
-			if len(finds) > 0 {
-				foundArgs := finds[0]
-				for i := 1; i < len(foundArgs); i++ {
-					unescaped, err := url.QueryUnescape(foundArgs[i])
-					//fmt.Println("  >>>>", tmpS.pathArgs[i-1], foundArgs[i], unescaped, err)
-					if err == nil {
-						args[tmpS.pathArgs[i-1]] = unescaped
-					} else {
-						args[tmpS.pathArgs[i-1]] = foundArgs[i]
-					}
-					s = tmpS
-				}
-				break
-			}
-		}
-	}
+	args := make(map[string]interface{})
+
+	// 匹配 Service 或 WebsocketService
+	s, ws := matchRoute(ctx.host, request.Method, requestPath, args)
 
-	// 未匹配到缓存和Service，尝试匹配新的WebsocketService
 	if s == nil && ws == nil {
-		//for _, tmpS := range regexWebsocketServices {
-		for i := len(regexWebsocketServices) - 1; i >= 0; i-- {
-			tmpS := regexWebsocketServices[i]
-			finds := tmpS.pathMatcher.FindAllStringSubmatch(requestPath, 20)
-			if len(finds) > 0 {
-				foundArgs := finds[0]
-				for i := 1; i < len(foundArgs); i++ {
-					args[tmpS.pathArgs[i-1]] = foundArgs[i]
-					ws = tmpS
-				}
-				break
+		// 路径存在但请求方法不匹配，OPTIONS 直接返回支持的方法，其他方法输出405
+		if methods := allowedMethods(ctx.host, requestPath); methods != nil {
+			myResponse.Header().Set("Allow", strings.Join(methods, ", "))
+			if request.Method == "OPTIONS" {
+				myResponse.WriteHeader(204)
+				writeLog(requestLogger, "ACCESS", nil, 0, request, myResponse, &args, &ctx.logHeaders, &ctx.StartTime, 0, nil)
+			} else {
+				myResponse.WriteHeader(405)
+				writeLog(requestLogger, "FAIL", nil, 0, request, myResponse, &args, &ctx.logHeaders, &ctx.StartTime, 0, nil)
 			}
+			return
 		}
-	}
 
-	// 全都未匹配，输出404
-	if s == nil && ws == nil {
-		response.WriteHeader(404)
+		// 全都未匹配，输出404
+		myResponse.WriteHeader(404)
 		if requestPath != "/favicon.ico" {
-			writeLog(requestLogger, "FAIL", nil, 0, request, myResponse, &args, &logHeaders, &startTime, 0, nil)
+			writeLog(requestLogger, "FAIL", nil, 0, request, myResponse, &args, &ctx.logHeaders, &ctx.StartTime, 0, nil)
 		}
 		return
 	}
+	// 分别记录路径、QueryString和请求内容中的参数，合并时路径中的参数不会被覆盖
+	sources := &argSources{path: args, query: map[string]interface{}{}, body: map[string]interface{}{}}
+
 	//判定是rewrite
 	// rewrite问号后的参数不能被request.Form解析 解析问号后的参数
 	if strings.Index(request.RequestURI, request.URL.Path) == -1 && strings.LastIndex(request.RequestURI, "?") != -1 {
@@ -269,209 +292,447 @@ func (rh *routeHandler) ServeHTTP(writer http.ResponseWriter, request *http.Requ
 		if reqErr == nil {
 			queryStringArr, reqErr := url.ParseQuery(requestUrl.RawQuery)
 			if reqErr == nil && len(queryStringArr) > 0 {
-				for paramName, paramValue := range queryStringArr {
-					if len(paramValue) < 1 {
-						continue
-					}
-					if len(paramValue) > 1 {
-						args[paramName] = paramValue
-					} else {
-						args[paramName] = paramValue[0]
-					}
-
-				}
+				setFormArgs(sources.query, queryStringArr)
 			}
 		}
 	}
+	// 限制请求内容的尺寸，服务单独设置的限制优先（包括接收 io.Reader 的服务）
+	contentType := request.Header.Get("Content-Type")
+	streaming := s != nil && s.readerIndex >= 0
+	if s != nil && request.Body != nil {
+		bodyLimit := Config.MaxBodySize
+		if strings.HasPrefix(contentType, "multipart/form-data") {
+			bodyLimit = Config.UploadMaxSize
+		}
+		if s.maxBodySize > 0 {
+			bodyLimit = s.maxBodySize
+		}
+		if bodyLimit > 0 {
+			if request.ContentLength > int64(bodyLimit) {
+				rejectBody(ctx, args, errBodyTooLarge)
+				return
+			}
+			request.Body = http.MaxBytesReader(myResponse, request.Body, int64(bodyLimit))
+		}
+	}
+
 	// GET POST
-	err := request.ParseForm()
-	if err != nil {
-		logError(err.Error())
-	} else {
-		reqForm := request.Form
-		for k, v := range reqForm {
-			if len(v) > 1 {
-				args[k] = v
-			} else {
-				args[k] = v[0]
+	setFormArgs(sources.query, request.URL.Query())
+	if !streaming {
+		// 请求内容由服务自己读取时不解析表单
+		if err := request.ParseForm(); err != nil {
+			if isBodyTooLarge(err) {
+				rejectBody(ctx, args, err)
+				return
 			}
+			logError(err.Error())
+		} else {
+			setFormArgs(sources.body, request.PostForm)
 		}
 	}
 
 	// POST
-	if request.Body != nil {
-		contentType := request.Header.Get("Content-Type")
-		if strings.HasPrefix(contentType, "application/json") {
-			bodyBytes, _ := ioutil.ReadAll(request.Body)
+	var requestCodec *codecType
+	if request.Body != nil && !streaming {
+		if codec := getCodec(contentType); codec != nil {
+			// 按 Content-Type 使用注册的编解码器，结果使用同样的格式输出
+			bodyBytes, err := ioutil.ReadAll(request.Body)
 			_ = request.Body.Close()
-			if len(bodyBytes) > 0 {
-				var err error
-				if bodyBytes[0] == 123 {
-					err = json.Unmarshal(bodyBytes, &args)
-				} else {
-					arg := new(interface{})
-					err = json.Unmarshal(bodyBytes, arg)
-					args["request"] = arg
-				}
-				if err != nil {
-					response.WriteHeader(400)
-					writeLog(requestLogger, "FAIL", nil, 0, request, myResponse, &args, &logHeaders, &startTime, 0, nil)
-					return
-				}
+			if err == nil && codec.mediaType == "application/json" && !checkJsonDepth(bodyBytes, Config.MaxJsonDepth) {
+				err = errJsonTooDeep
+			}
+			if err == nil && len(bodyBytes) > 0 {
+				err = decodeBody(codec, bodyBytes, sources.body)
+			}
+			if err != nil {
+				rejectBody(ctx, args, err)
+				return
+			}
+			requestCodec = codec
+		} else if strings.HasPrefix(contentType, "multipart/form-data") {
+			// 超过 UploadMemorySize 的文件写入临时文件，请求结束后删除
+			err := request.ParseMultipartForm(int64(Config.UploadMemorySize))
+			if request.MultipartForm != nil {
+				defer func() {
+					// 超时后服务仍在运行时，服务返回后再删除
+					form := request.MultipartForm
+					afterService(ctx.Request.Context(), func() {
+						_ = form.RemoveAll()
+					})
+				}()
+			}
+			if err != nil {
+				rejectBody(ctx, args, err)
+				return
 			}
+			setFormArgs(sources.body, request.MultipartForm.Value)
 		} else if contentType == "application/x-www-form-urlencoded" {
-			bodyBytes, _ := ioutil.ReadAll(request.Body)
+			bodyBytes, err := ioutil.ReadAll(request.Body)
 			_ = request.Body.Close()
+			if err != nil {
+				rejectBody(ctx, args, err)
+				return
+			}
 			argsBody, err := url.ParseQuery(string(bodyBytes))
 			if err == nil && len(argsBody) > 0 {
-				for aKey, aBody := range argsBody {
-					if len(aBody) > 1 {
-						args[aKey] = aBody
-					} else {
-						args[aKey] = aBody[0]
-					}
-				}
+				setFormArgs(sources.body, argsBody)
 			}
 		}
 	}
 
-	// 身份认证
-	var authLevel = 0
+	args = sources.merge()
+	ctx.sources = sources
+	ctx.Args = args
+	ctx.service = s
+	ctx.websocketService = ws
+
+	// 分组和服务的中间件，外层是结果输出，最内层是过滤器和身份认证
+	serviceMiddlewares := make([]func(*Context, func()), 0)
+	if ws == nil {
+		serviceMiddlewares = append(serviceMiddlewares, outputMiddleware)
+	}
+	var serviceMiddlewaresOfRoute []func(*Context, func())
 	if ws != nil {
-		authLevel = ws.authLevel
-	} else if s != nil {
-		authLevel = s.authLevel
+		ctx.authLevel = ws.authLevel
+		ctx.group = ws.group
+		serviceMiddlewaresOfRoute = ws.middlewares
+	} else {
+		ctx.authLevel = s.authLevel
+		ctx.group = s.group
+		serviceMiddlewaresOfRoute = s.middlewares
+		processCors(s, request, myResponse)
+
+		// 按 Accept 选择输出的格式，SSE 和直接输出的结果不需要协商
+		if s.sseIndex < 0 {
+			ctx.codec = negotiateCodec(request.Header.Get("Accept"), s.formats, requestCodec)
+			if ctx.codec == nil {
+				var outType reflect.Type
+				if s.funcType.NumOut() > 0 && s.errorIndex != 0 {
+					outType = s.funcType.Out(0)
+				}
+				if isDynamicOutType(outType) {
+					// 输出时按结果的类型判断
+					ctx.notAcceptable = true
+				} else if !isRawOutType(outType) {
+					myResponse.WriteHeader(406)
+					writeLog(requestLogger, "FAIL", nil, 0, request, myResponse, &args, &ctx.logHeaders, &ctx.StartTime, ctx.authLevel, nil)
+					return
+				}
+			}
+		}
+	}
+	if ctx.group != nil {
+		for _, g := range ctx.group.chain() {
+			serviceMiddlewares = append(serviceMiddlewares, g.middlewares...)
+		}
 	}
+	serviceMiddlewares = append(serviceMiddlewares, serviceMiddlewaresOfRoute...)
 
-	defer func() {
-		if err := recover(); err != nil {
-			var out interface{}
-			if errorHandle != nil {
-				out = errorHandle(err, request, &response)
-			} else {
-				response.WriteHeader(ResponseCodePanicError)
-				out = ""
+	if ws != nil {
+		serviceMiddlewares = append(serviceMiddlewares, inFilterMiddleware, authMiddleware)
+		runMiddlewares(serviceMiddlewares, ctx, func() {
+			// 处理 Websocket
+			if ctx.Result == nil {
+				doWebsocketService(ws, request, myResponse, ctx.authLevel, &ctx.Args, &ctx.logHeaders, &ctx.StartTime, requestLogger)
 			}
+		})
+	} else {
+		// 服务的 context 在结果输出完成后才结束，流式输出时可以继续使用
+		if serviceCtx, cancel := makeServiceContext(s, request); cancel != nil {
+			ctx.cancel = cancel
+			request = request.WithContext(serviceCtx)
+			ctx.Request = request
+		}
+		serviceMiddlewares = append(serviceMiddlewares, outFilterMiddleware, inFilterMiddleware, authMiddleware)
+		runMiddlewares(serviceMiddlewares, ctx, func() {
+			ctx.Result = doWebService(s, request, &ctx.writer, &ctx.Args, ctx.sources, ctx.Result, requestLogger)
+			takeResult(ctx)
+		})
+	}
+}
 
-			logError(u.String(err))
-			writeLog(requestLogger, "PANIC", out, myResponse.outLen, request, myResponse, &args, &logHeaders, &startTime, authLevel, Map{
-				"error": err,
-			})
+var errBodyTooLarge = fmt.Errorf("http: request body too large")
+var errJsonTooDeep = fmt.Errorf("json: exceeded max depth")
+
+func isBodyTooLarge(err error) bool {
+	return strings.Contains(err.Error(), "request body too large")
+}
+
+// 请求内容超过限制时返回 413，无法解析时返回 400
+func rejectBody(ctx *Context, args map[string]interface{}, err error) {
+	if isBodyTooLarge(err) {
+		ctx.Response.WriteHeader(413)
+	} else {
+		ctx.Response.WriteHeader(400)
+	}
+	writeLog(ctx.Logger, "FAIL", nil, 0, ctx.Request, ctx.Response, &args, &ctx.logHeaders, &ctx.StartTime, 0, Map{"error": err.Error()})
+}
+
+// 检查 JSON 的嵌套层数，max 为 0 时不限制
+func checkJsonDepth(data []byte, max int) bool {
+	if max <= 0 {
+		return true
+	}
+	depth := 0
+	inString := false
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		if inString {
+			if c == '\\' {
+				i++
+			} else if c == '"' {
+				inString = false
+			}
+			continue
+		}
+		switch c {
+		case '"':
+			inString = true
+		case '{', '[':
+			depth++
+			if depth > max {
+				return false
+			}
+		case '}', ']':
+			depth--
 		}
+	}
+	return true
+}
+
+// 检查解码后数据的嵌套层数，max 为 0 时不限制
+func checkValueDepth(value interface{}, max int) bool {
+	if max <= 0 {
+		return true
+	}
+	return isWithinDepth(value, max)
+}
 
-		if sessionObjects[request] != nil {
-			delete(sessionObjects, request)
+func isWithinDepth(value interface{}, remain int) bool {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if remain == 0 {
+			return false
 		}
-	}()
+		for _, item := range v {
+			if !isWithinDepth(item, remain-1) {
+				return false
+			}
+		}
+	case []interface{}:
+		if remain == 0 {
+			return false
+		}
+		for _, item := range v {
+			if !isWithinDepth(item, remain-1) {
+				return false
+			}
+		}
+	}
+	return true
+}
 
-	// 前置过滤器
-	var result interface{} = nil
+// 前置过滤器
+func inFilterMiddleware(ctx *Context, next func()) {
 	for _, filter := range inFilters {
-		result = filter(&args, request, &response)
-		if result != nil {
+		ctx.Result = filter(&ctx.Args, ctx.Request, &ctx.writer)
+		if ctx.Result != nil {
 			break
 		}
 	}
-	if authLevel > 0 {
+	if ctx.Result == nil && ctx.group != nil {
+		for _, g := range ctx.group.chain() {
+			for _, filter := range g.inFilters {
+				ctx.Result = filter(&ctx.Args, ctx.Request, &ctx.writer)
+				if ctx.Result != nil {
+					break
+				}
+			}
+			if ctx.Result != nil {
+				break
+			}
+		}
+	}
+	next()
+}
+
+// 身份认证
+func authMiddleware(ctx *Context, next func()) {
+	if ctx.authLevel > 0 {
 		if webAuthChecker == nil {
 			SetAuthChecker(func(authLevel int, url *string, in *map[string]interface{}, request *http.Request) bool {
 				settedAuthLevel := accessTokens[request.Header.Get("Access-Token")]
 				return settedAuthLevel != nil && *settedAuthLevel >= authLevel
 			})
 		}
-		if webAuthChecker(authLevel, &request.RequestURI, &args, request) == false {
-			//usedTime := float32(time.Now().UnixNano()-startTime.UnixNano()) / 1e6
-			//byteArgs, _ := json.Marshal(args)
-			//byteHeaders, _ := json.Marshal(logHeaders)
-			//log.Printf("REJECT	%s	%s	%s	%s	%.6f	%s	%s	%d	%s", request.RemoteAddr, request.Host, request.Method, request.RequestURI, usedTime, string(byteArgs), string(byteHeaders), authLevel, request.Proto)
-			response.WriteHeader(403)
-			writeLog(requestLogger, "REJECT", result, 0, request, myResponse, &args, &logHeaders, &startTime, authLevel, nil)
+		authChecker := webAuthChecker
+		if ctx.group != nil && ctx.group.getAuthChecker() != nil {
+			authChecker = ctx.group.getAuthChecker()
+		}
+		if authChecker(ctx.authLevel, &ctx.Request.RequestURI, &ctx.Args, ctx.Request) == false {
+			ctx.Response.WriteHeader(403)
+			writeLog(ctx.Logger, "REJECT", ctx.Result, 0, ctx.Request, ctx.Response, &ctx.Args, &ctx.logHeaders, &ctx.StartTime, ctx.authLevel, nil)
+			ctx.rejected = true
 			return
 		}
 	}
+	next()
+}
 
-	// 处理 Proxy
-	//var logName string
-	//var statusCode int
-	//if proxyToApp != nil {
-	//	caller := &Caller{request: request}
-	//	r := caller.Do(request.Method, *proxyToApp, *proxyToPath, args, "Host", request.Host)
-	//	result = r.Bytes()
-	//	statusCode = 500
-	//	if r.Error == nil && r.Response != nil {
-	//		statusCode = r.Response.StatusCode
-	//	}
-	//	logName = "PROXY"
-	//} else {
-	// 处理 Websocket
-	if ws != nil && result == nil {
-		doWebsocketService(ws, request, myResponse, authLevel, &args, &logHeaders, &startTime, requestLogger)
-	} else if s != nil || result != nil {
-		result = doWebService(s, request, &response, &args, result, requestLogger)
-		//logName = "ACCESS"
-		//statusCode = 200
-	}
-	//}
+// 后置过滤器
+func outFilterMiddleware(ctx *Context, next func()) {
+	next()
+	if ctx.rejected {
+		return
+	}
 
-	if ws == nil {
-		// 后置过滤器
-		for _, filter := range outFilters {
-			newResult, done := filter(&args, request, &response, result)
-			if newResult != nil {
-				result = newResult
+	done := false
+	for _, filter := range outFilters {
+		var newResult interface{}
+		newResult, done = filter(&ctx.Args, ctx.Request, &ctx.writer, ctx.Result)
+		if newResult != nil {
+			ctx.Result = newResult
+		}
+		if done {
+			break
+		}
+	}
+	if !done && ctx.group != nil {
+		for _, g := range ctx.group.chain() {
+			for _, filter := range g.outFilters {
+				var newResult interface{}
+				newResult, done = filter(&ctx.Args, ctx.Request, &ctx.writer, ctx.Result)
+				if newResult != nil {
+					ctx.Result = newResult
+				}
+				if done {
+					break
+				}
 			}
 			if done {
 				break
 			}
 		}
-		// 返回结果
-		outType := reflect.TypeOf(result)
-		if outType == nil {
+	}
+}
+
+// 输出结果
+func outputMiddleware(ctx *Context, next func()) {
+	if ctx.cancel != nil {
+		defer ctx.cancel()
+	}
+	next()
+	if ctx.rejected {
+		return
+	}
+
+	// 后置过滤器也可以返回 Result
+	takeResult(ctx)
+	result := ctx.Result
+	response := ctx.writer
+	request := ctx.Request
+	output := ctx.output
+	if output != nil {
+		output.writeHeaders(response)
+	}
+	// 返回结果
+	outType := reflect.TypeOf(result)
+	if outType == nil {
+		if output != nil && output.Status != 0 {
+			response.WriteHeader(output.Status)
+			writeLog(ctx.Logger, "ACCESS", nil, 0, request, ctx.Response, &ctx.Args, &ctx.logHeaders, &ctx.StartTime, ctx.authLevel, nil)
+		}
+		return
+	}
+	if sender, ok := result.(*SSESender); ok {
+		extraInfo := Map{"sse": true, "events": sender.events}
+		if sender.err != nil {
+			extraInfo["error"] = sender.err.Error()
+		}
+		writeLog(ctx.Logger, "ACCESS", nil, ctx.Response.outLen, request, ctx.Response, &ctx.Args, &ctx.logHeaders, &ctx.StartTime, ctx.authLevel, extraInfo)
+		return
+	}
+	if isStreamResult(result) {
+		writeStream(ctx, result)
+		return
+	}
+	for outType.Kind() == reflect.Ptr {
+		outType = outType.Elem()
+	}
+	var outBytes []byte
+	if outType.Kind() != reflect.String && (outType.Kind() != reflect.Slice || outType.Elem().Kind() != reflect.Uint8) {
+		if ctx.notAcceptable {
+			response.WriteHeader(406)
+			writeLog(ctx.Logger, "FAIL", nil, 0, request, ctx.Response, &ctx.Args, &ctx.logHeaders, &ctx.StartTime, ctx.authLevel, nil)
 			return
 		}
-		for outType.Kind() == reflect.Ptr {
-			outType = outType.Elem()
+		mediaType := "application/json"
+		var err error
+		if ctx.codec != nil {
+			mediaType = ctx.codec.mediaType
+			outBytes, err = ctx.codec.encoder(result)
 		}
-		var outBytes []byte
-		if outType.Kind() != reflect.String && (outType.Kind() != reflect.Slice || outType.Elem().Kind() != reflect.Uint8) {
+		if ctx.codec == nil || err != nil {
+			// 无法使用选择的格式输出时使用 JSON
+			if err != nil && err != errUseJson {
+				logError(err.Error(), "mediaType", mediaType)
+			}
+			mediaType = "application/json"
 			outBytes = makeBytesResult(result)
-		} else if outType.Kind() == reflect.String {
-			outBytes = []byte(result.(string))
-		} else {
-			outBytes = result.([]byte)
 		}
-
-		isZipOuted := false
-		if Config.Compress && len(outBytes) >= Config.CompressMinSize && len(outBytes) <= Config.CompressMaxSize && strings.Contains(request.Header.Get("Accept-Encoding"), "gzip") {
-			zipWriter, err := gzip.NewWriterLevel(response, 1)
-			if err == nil {
-				response.Header().Set("Content-Encoding", "gzip")
-				n, err := zipWriter.Write(outBytes)
-				if err != nil {
-					logError(err.Error(), "wrote", n)
-				} else {
-					isZipOuted = true
-				}
-				_ = zipWriter.Close()
-			}
+		if response.Header().Get("Content-Type") == "" {
+			response.Header().Set("Content-Type", mediaType+u.StringIf(strings.HasPrefix(mediaType, "text/"), "; charset=utf-8", ""))
 		}
+	} else if outType.Kind() == reflect.String {
+		outBytes = []byte(result.(string))
+	} else {
+		outBytes = result.([]byte)
+	}
 
-		if !isZipOuted {
-			n, err := response.Write(outBytes)
+	sendBytes := outBytes
+	if Config.Compress && len(outBytes) >= Config.CompressMinSize && len(outBytes) <= Config.CompressMaxSize && strings.Contains(request.Header.Get("Accept-Encoding"), "gzip") {
+		zipBuffer := new(bytes.Buffer)
+		zipWriter, err := gzip.NewWriterLevel(zipBuffer, 1)
+		if err == nil {
+			n, err := zipWriter.Write(outBytes)
+			_ = zipWriter.Close()
 			if err != nil {
 				logError(err.Error(), "wrote", n)
+			} else {
+				response.Header().Set("Content-Encoding", "gzip")
+				sendBytes = zipBuffer.Bytes()
 			}
 		}
+	}
 
-		// 记录访问日志
-		outLen := 0
-		if outBytes != nil {
-			outLen = len(outBytes)
+	if request.Method == "HEAD" {
+		// HEAD 请求只输出与 GET 一致的 Content-Length，不发送内容
+		response.Header().Set("Content-Length", strconv.Itoa(len(sendBytes)))
+		if output != nil && output.Status != 0 {
+			response.WriteHeader(output.Status)
+		}
+	} else {
+		if output != nil && output.Status != 0 {
+			response.WriteHeader(output.Status)
 		}
-		if requestPath != "/__CHECK__" {
-			writeLog(requestLogger, "ACCESS", result, outLen, request, myResponse, &args, &logHeaders, &startTime, authLevel, nil)
+		n, err := response.Write(sendBytes)
+		if err != nil {
+			logError(err.Error(), "wrote", n)
 		}
 	}
+
+	// 记录访问日志
+	outLen := 0
+	if outBytes != nil {
+		outLen = len(outBytes)
+	}
+	if httpErr, ok := result.(*HTTPError); ok {
+		writeLog(ctx.Logger, u.StringIf(isTimeoutError(httpErr.cause), "TIMEOUT", "ERROR"), result, outLen, request, ctx.Response, &ctx.Args, &ctx.logHeaders, &ctx.StartTime, ctx.authLevel, Map{
+			"error": httpErr.logMessage(),
+		})
+	} else if ctx.requestPath != "/__CHECK__" {
+		writeLog(ctx.Logger, "ACCESS", result, outLen, request, ctx.Response, &ctx.Args, &ctx.logHeaders, &ctx.StartTime, ctx.authLevel, nil)
+	}
 }
 
 func requireEncryptField(k string) bool {
@@ -575,8 +836,11 @@ func makeLogableData(v reflect.Value, allows *map[string]bool, numArrays int, le
 	case reflect.Struct:
 		v2 := reflect.MakeMap(reflect.TypeOf(Map{}))
 		for i := 0; i < v.NumField(); i++ {
-			k := t.Field(i).Name
-			if level == 1 && allows != nil && (*allows)[strings.ToLower(k)] == false {
+			k, _ := fieldOutputName(t.Field(i))
+			if k == "" || t.Field(i).PkgPath != "" {
+				continue
+			}
+			if level == 1 && allows != nil && (*allows)[strings.ToLower(k)] == false && (*allows)[strings.ToLower(t.Field(i).Name)] == false {
 				continue
 			}
 			if requireEncryptField(k) {
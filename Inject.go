@@ -0,0 +1,242 @@
+package s
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sync"
+
+	"github.com/ssgo/log"
+)
+
+// 注入对象的生命周期
+const (
+	// 整个服务只创建一次
+	InjectSingleton = iota + 1
+	// 每个请求创建一次，Websocket 中每个 action 创建一次
+	InjectPerRequest
+	// 每个连接创建一次，Websocket 中整个连接共用，Http 请求中与 InjectPerRequest 相同
+	InjectPerConnection
+)
+
+type injectProvider struct {
+	lifetime  int
+	funcType  reflect.Type
+	funcValue reflect.Value
+	lock      sync.Mutex
+	created   bool
+	value     reflect.Value
+}
+
+// 请求中的注入对象，保存在 request 的 context 中
+type injectScope struct {
+	lock    sync.Mutex
+	objects map[reflect.Type]reflect.Value
+}
+
+type injectScopeKey struct{}
+
+var injectObjects = map[reflect.Type]interface{}{}
+var injectProviders = map[reflect.Type]*injectProvider{}
+var loggerType = reflect.TypeOf((*log.Logger)(nil))
+var requestType = reflect.TypeOf((*http.Request)(nil))
+
+// 设置一个生命周期在 Request 中的对象，请求中可以使用对象类型注入参数方便调用
+func SetSessionInject(request *http.Request, obj interface{}) {
+	scope := getInjectScope(request)
+	if scope == nil {
+		logError("no inject scope in request", "type", reflect.TypeOf(obj).String())
+		return
+	}
+	scope.lock.Lock()
+	scope.objects[reflect.TypeOf(obj)] = reflect.ValueOf(obj)
+	scope.lock.Unlock()
+}
+
+// 获取本生命周期中指定类型的 Session 对象
+func GetSessionInject(request *http.Request, dataType reflect.Type) interface{} {
+	scope := getInjectScope(request)
+	if scope == nil {
+		return nil
+	}
+	scope.lock.Lock()
+	defer scope.lock.Unlock()
+	if v, ok := scope.objects[dataType]; ok {
+		return v.Interface()
+	}
+	return nil
+}
+
+// 设置一个注入对象，请求中可以使用对象类型注入参数方便调用
+// 对象有 CopyByLogger(*log.Logger) 方法时，每个请求使用该方法返回的设置了请求日志的新对象
+// 其他对象在所有请求中共用，不会调用 SetLogger，需要请求日志时使用 InjectPerRequest 的 Provider
+func SetInject(obj interface{}) {
+	t := reflect.TypeOf(obj)
+	if _, found := t.MethodByName("SetLogger"); found && !getCopyByLogger(reflect.ValueOf(obj), t).IsValid() {
+		logError("inject object with SetLogger is shared by all requests, use CopyByLogger or an InjectPerRequest provider", "type", t.String())
+	}
+	injectObjects[t] = obj
+}
+
+// 获取一个注入对象
+func GetInject(dataType reflect.Type) interface{} {
+	return injectObjects[dataType]
+}
+
+// 设置创建注入对象的函数，按函数的返回类型注入（可以是接口）
+// 函数可以使用 *http.Request、*log.Logger、context.Context 参数，可以返回 (T, error)
+func SetProvider(lifetime int, provider interface{}) {
+	funcType := reflect.TypeOf(provider)
+	if funcType == nil || funcType.Kind() != reflect.Func || funcType.NumOut() < 1 || funcType.NumOut() > 2 || (funcType.NumOut() == 2 && funcType.Out(1) != errorType) {
+		logError("bad provider", "type", fmt.Sprint(funcType))
+		return
+	}
+	if lifetime != InjectSingleton && lifetime != InjectPerRequest && lifetime != InjectPerConnection {
+		logError("bad provider lifetime", "type", funcType.String(), "lifetime", lifetime)
+		return
+	}
+	injectProviders[funcType.Out(0)] = &injectProvider{lifetime: lifetime, funcType: funcType, funcValue: reflect.ValueOf(provider)}
+}
+
+func withInjectScope(request *http.Request) *http.Request {
+	return request.WithContext(context.WithValue(request.Context(), injectScopeKey{}, &injectScope{objects: map[reflect.Type]reflect.Value{}}))
+}
+
+func getInjectScope(request *http.Request) *injectScope {
+	if request == nil {
+		return nil
+	}
+	scope, _ := request.Context().Value(injectScopeKey{}).(*injectScope)
+	return scope
+}
+
+// 获取参数的注入对象，顺序为：SessionInject、Provider、SetInject，都没有时使用零值
+// inAction 为 true 时（Websocket 的 action）InjectPerRequest 的对象每次重新创建
+func resolveInject(request *http.Request, t reflect.Type, logger *log.Logger, inAction bool) (reflect.Value, error) {
+	scope := getInjectScope(request)
+	if scope != nil {
+		scope.lock.Lock()
+		v, ok := scope.objects[t]
+		scope.lock.Unlock()
+		if ok {
+			return v, nil
+		}
+	}
+
+	if provider := injectProviders[t]; provider != nil {
+		if provider.lifetime == InjectSingleton {
+			return provider.singleton()
+		}
+		if scope == nil || (inAction && provider.lifetime == InjectPerRequest) {
+			return provider.make(request, logger)
+		}
+		// 创建时不持有锁，provider 中可以使用 GetSessionInject、SetSessionInject
+		v, err := provider.make(request, logger)
+		if err != nil {
+			return v, err
+		}
+		scope.lock.Lock()
+		defer scope.lock.Unlock()
+		if created, ok := scope.objects[t]; ok {
+			return created, nil
+		}
+		scope.objects[t] = v
+		return v, nil
+	}
+
+	if obj := findInject(t); obj != nil {
+		return withRequestLogger(reflect.ValueOf(obj), t, logger), nil
+	}
+	return reflect.New(t).Elem(), nil
+}
+
+// 接口类型使用唯一实现了该接口的注入对象
+func findInject(t reflect.Type) interface{} {
+	if obj := injectObjects[t]; obj != nil {
+		return obj
+	}
+	if t.Kind() != reflect.Interface {
+		return nil
+	}
+	var found interface{}
+	for objType, obj := range injectObjects {
+		if objType.Implements(t) {
+			if found != nil {
+				logError("multiple inject objects implement "+t.String(), "type", t.String())
+				return nil
+			}
+			found = obj
+		}
+	}
+	return found
+}
+
+// 使用 CopyByLogger 得到设置了请求日志的新对象，共用的注入对象不做修改，避免并发的请求互相覆盖
+func withRequestLogger(v reflect.Value, t reflect.Type, logger *log.Logger) reflect.Value {
+	method := getCopyByLogger(v, t)
+	if !method.IsValid() {
+		return v
+	}
+	copied := method.Call([]reflect.Value{reflect.ValueOf(logger)})[0]
+	switch copied.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if copied.IsNil() {
+			return v
+		}
+	}
+	return copied
+}
+
+// 获取对象的 CopyByLogger(*log.Logger) T 方法，返回值需要可以作为 t 类型注入
+func getCopyByLogger(v reflect.Value, t reflect.Type) reflect.Value {
+	method := v.MethodByName("CopyByLogger")
+	if !method.IsValid() {
+		return method
+	}
+	methodType := method.Type()
+	if methodType.NumIn() != 1 || methodType.In(0) != loggerType || methodType.NumOut() != 1 || !methodType.Out(0).AssignableTo(t) {
+		return reflect.Value{}
+	}
+	return method
+}
+
+func (provider *injectProvider) singleton() (reflect.Value, error) {
+	provider.lock.Lock()
+	defer provider.lock.Unlock()
+	if provider.created {
+		return provider.value, nil
+	}
+	v, err := provider.make(nil, log.DefaultLogger)
+	if err == nil {
+		provider.value = v
+		provider.created = true
+	}
+	return v, err
+}
+
+func (provider *injectProvider) make(request *http.Request, logger *log.Logger) (reflect.Value, error) {
+	parms := make([]reflect.Value, provider.funcType.NumIn())
+	for i := range parms {
+		t := provider.funcType.In(i)
+		switch {
+		case t == requestType && request != nil:
+			parms[i] = reflect.ValueOf(request)
+		case t == loggerType && logger != nil:
+			parms[i] = reflect.ValueOf(logger)
+		case t == contextType:
+			if request != nil {
+				parms[i] = reflect.ValueOf(request.Context())
+			} else {
+				parms[i] = reflect.ValueOf(context.Background())
+			}
+		default:
+			parms[i] = reflect.New(t).Elem()
+		}
+	}
+	outs := provider.funcValue.Call(parms)
+	if len(outs) == 2 && !outs[1].IsNil() {
+		return outs[0], outs[1].Interface().(error)
+	}
+	return outs[0], nil
+}
@@ -0,0 +1,94 @@
+package s
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/ssgo/log"
+)
+
+// 请求上下文，在中间件中使用
+type Context struct {
+	Request   *http.Request
+	Response  *Response
+	Logger    *log.Logger
+	StartTime time.Time
+	// 请求参数，匹配到服务后才有值
+	Args map[string]interface{}
+	// 服务的返回结果，next 返回后可以读取或修改，输出前生效
+	Result interface{}
+
+	writer           http.ResponseWriter
+	logHeaders       map[string]string
+	host             string
+	requestPath      string
+	authLevel        int
+	group            *ServiceGroup
+	service          *webServiceType
+	websocketService *websocketServiceType
+	codec            *codecType
+	sources          *argSources
+	rejected         bool
+	notAcceptable    bool
+	output           *Result
+	cancel           func()
+}
+
+var middlewares = make([]func(*Context, func()), 0)
+
+// 请求的路径，不包含 QueryString，Rewrite 后为新的路径
+func (ctx *Context) Path() string {
+	return ctx.requestPath
+}
+
+// 请求的域名，不包含端口
+func (ctx *Context) Host() string {
+	return ctx.host
+}
+
+// 服务的授权等级
+func (ctx *Context) AuthLevel() int {
+	return ctx.authLevel
+}
+
+// 添加全局中间件，包裹 Rewrite、Proxy、静态文件、Web服务和Websocket服务的全部处理过程
+// 中间件调用 next 执行后续的处理，不调用 next 则中断请求，需要自行输出结果
+func Use(middleware func(ctx *Context, next func())) {
+	middlewares = append(middlewares, middleware)
+}
+
+// 添加分组的中间件，包裹组内服务的处理过程，在全局中间件之内执行
+func (g *ServiceGroup) Use(middleware func(ctx *Context, next func())) *ServiceGroup {
+	g.middlewares = append(g.middlewares, middleware)
+	return g
+}
+
+// 添加服务的中间件，在全局和分组的中间件之内执行
+func (sr *ServiceRegister) Use(middleware func(ctx *Context, next func())) *ServiceRegister {
+	if sr.service != nil {
+		sr.service.middlewares = append(sr.service.middlewares, middleware)
+	}
+	return sr
+}
+
+// 添加Websocket服务的中间件，在全局和分组的中间件之内执行
+func (ar *ActionRegister) Use(middleware func(ctx *Context, next func())) *ActionRegister {
+	if ar.websocketServiceType != nil {
+		ar.websocketServiceType.middlewares = append(ar.websocketServiceType.middlewares, middleware)
+	}
+	return ar
+}
+
+func runMiddlewares(middlewares []func(*Context, func()), ctx *Context, handler func()) {
+	var next func(int)
+	next = func(i int) {
+		if i < len(middlewares) {
+			middlewares[i](ctx, func() {
+				next(i + 1)
+			})
+		} else {
+			handler()
+		}
+	}
+	next(0)
+}
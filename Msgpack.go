@@ -0,0 +1,313 @@
+package s
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+)
+
+var errMsgpackShort = fmt.Errorf("msgpack: unexpected end of data")
+var errMsgpackTooDeep = fmt.Errorf("msgpack: exceeded max depth")
+
+// 读取一个 MessagePack 值，返回值和下一个值的位置，整数解码为 int64 或 uint64，map 解码为 map[string]interface{}
+// depth 为当前的嵌套层数，超过 MaxJsonDepth 时返回错误
+func readMsgpack(data []byte, pos int, depth int) (interface{}, int, error) {
+	if pos >= len(data) {
+		return nil, pos, errMsgpackShort
+	}
+	c := data[pos]
+	pos++
+	switch {
+	case c <= 0x7f:
+		return int64(c), pos, nil
+	case c >= 0xe0:
+		return int64(int8(c)), pos, nil
+	case c >= 0x80 && c <= 0x8f:
+		return readMsgpackMap(data, pos, int(c&0x0f), depth+1)
+	case c >= 0x90 && c <= 0x9f:
+		return readMsgpackArray(data, pos, int(c&0x0f), depth+1)
+	case c >= 0xa0 && c <= 0xbf:
+		return readMsgpackString(data, pos, int(c&0x1f))
+	}
+
+	switch c {
+	case 0xc0:
+		return nil, pos, nil
+	case 0xc2:
+		return false, pos, nil
+	case 0xc3:
+		return true, pos, nil
+	case 0xc4, 0xc5, 0xc6:
+		n, next, err := readMsgpackLength(data, pos, 1<<(c-0xc4))
+		if err != nil {
+			return nil, next, err
+		}
+		if next+n > len(data) {
+			return nil, next, errMsgpackShort
+		}
+		return append([]byte{}, data[next:next+n]...), next + n, nil
+	case 0xc7, 0xc8, 0xc9:
+		// ext 类型不做解析，跳过 type 后返回原始数据
+		n, next, err := readMsgpackLength(data, pos, 1<<(c-0xc7))
+		if err != nil {
+			return nil, next, err
+		}
+		if next+1+n > len(data) {
+			return nil, next, errMsgpackShort
+		}
+		return append([]byte{}, data[next+1:next+1+n]...), next + 1 + n, nil
+	case 0xca:
+		if pos+4 > len(data) {
+			return nil, pos, errMsgpackShort
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(data[pos:]))), pos + 4, nil
+	case 0xcb:
+		if pos+8 > len(data) {
+			return nil, pos, errMsgpackShort
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(data[pos:])), pos + 8, nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		size := 1 << (c - 0xcc)
+		if pos+size > len(data) {
+			return nil, pos, errMsgpackShort
+		}
+		n := readMsgpackUint(data[pos : pos+size])
+		if n <= math.MaxInt64 {
+			return int64(n), pos + size, nil
+		}
+		return n, pos + size, nil
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (c - 0xd0)
+		if pos+size > len(data) {
+			return nil, pos, errMsgpackShort
+		}
+		n := readMsgpackUint(data[pos : pos+size])
+		shift := uint(64 - size*8)
+		return int64(n<<shift) >> shift, pos + size, nil
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		size := 1 << (c - 0xd4)
+		if pos+1+size > len(data) {
+			return nil, pos, errMsgpackShort
+		}
+		return append([]byte{}, data[pos+1:pos+1+size]...), pos + 1 + size, nil
+	case 0xd9, 0xda, 0xdb:
+		n, next, err := readMsgpackLength(data, pos, 1<<(c-0xd9))
+		if err != nil {
+			return nil, next, err
+		}
+		return readMsgpackString(data, next, n)
+	case 0xdc, 0xdd:
+		n, next, err := readMsgpackLength(data, pos, 2<<(c-0xdc))
+		if err != nil {
+			return nil, next, err
+		}
+		return readMsgpackArray(data, next, n, depth+1)
+	case 0xde, 0xdf:
+		n, next, err := readMsgpackLength(data, pos, 2<<(c-0xde))
+		if err != nil {
+			return nil, next, err
+		}
+		return readMsgpackMap(data, next, n, depth+1)
+	}
+	return nil, pos, fmt.Errorf("msgpack: unknown format 0x%x", c)
+}
+
+func readMsgpackUint(data []byte) uint64 {
+	var n uint64
+	for _, b := range data {
+		n = n<<8 | uint64(b)
+	}
+	return n
+}
+
+func readMsgpackLength(data []byte, pos, size int) (int, int, error) {
+	if pos+size > len(data) {
+		return 0, pos, errMsgpackShort
+	}
+	n := readMsgpackUint(data[pos : pos+size])
+	if n > uint64(len(data)) {
+		return 0, pos, errMsgpackShort
+	}
+	return int(n), pos + size, nil
+}
+
+func readMsgpackString(data []byte, pos, n int) (interface{}, int, error) {
+	if pos+n > len(data) {
+		return nil, pos, errMsgpackShort
+	}
+	return string(data[pos : pos+n]), pos + n, nil
+}
+
+func readMsgpackArray(data []byte, pos, n, depth int) (interface{}, int, error) {
+	if Config.MaxJsonDepth > 0 && depth > Config.MaxJsonDepth {
+		return nil, pos, errMsgpackTooDeep
+	}
+	if n > len(data)-pos {
+		return nil, pos, errMsgpackShort
+	}
+	list := make([]interface{}, n)
+	for i := 0; i < n; i++ {
+		var err error
+		list[i], pos, err = readMsgpack(data, pos, depth)
+		if err != nil {
+			return nil, pos, err
+		}
+	}
+	return list, pos, nil
+}
+
+func readMsgpackMap(data []byte, pos, n, depth int) (interface{}, int, error) {
+	if Config.MaxJsonDepth > 0 && depth > Config.MaxJsonDepth {
+		return nil, pos, errMsgpackTooDeep
+	}
+	if n > len(data)-pos {
+		return nil, pos, errMsgpackShort
+	}
+	m := make(map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		var k, v interface{}
+		var err error
+		k, pos, err = readMsgpack(data, pos, depth)
+		if err != nil {
+			return nil, pos, err
+		}
+		v, pos, err = readMsgpack(data, pos, depth)
+		if err != nil {
+			return nil, pos, err
+		}
+		if key, ok := k.(string); ok {
+			m[key] = v
+		} else if key, ok := k.([]byte); ok {
+			m[string(key)] = v
+		} else {
+			m[fmt.Sprint(k)] = v
+		}
+	}
+	return m, pos, nil
+}
+
+// 写入一个 MessagePack 值，支持 JSON 的基础类型（包括 json.Number）以及整数、[]byte
+func writeMsgpack(buf *bytes.Buffer, data interface{}) error {
+	switch v := data.(type) {
+	case nil:
+		buf.WriteByte(0xc0)
+	case bool:
+		if v {
+			buf.WriteByte(0xc3)
+		} else {
+			buf.WriteByte(0xc2)
+		}
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			writeMsgpackInt(buf, n)
+		} else if f, err := v.Float64(); err == nil {
+			writeMsgpackFloat(buf, f)
+		} else {
+			return err
+		}
+	case float64:
+		writeMsgpackFloat(buf, v)
+	case float32:
+		writeMsgpackFloat(buf, float64(v))
+	case int:
+		writeMsgpackInt(buf, int64(v))
+	case int64:
+		writeMsgpackInt(buf, v)
+	case int32:
+		writeMsgpackInt(buf, int64(v))
+	case uint64:
+		if v > math.MaxInt64 {
+			buf.WriteByte(0xcf)
+			writeMsgpackUint(buf, v, 8)
+		} else {
+			writeMsgpackInt(buf, int64(v))
+		}
+	case string:
+		writeMsgpackHeader(buf, len(v), 0xa0, 31, 0xd9, true)
+		buf.WriteString(v)
+	case []byte:
+		writeMsgpackHeader(buf, len(v), 0, -1, 0xc4, true)
+		buf.Write(v)
+	case []interface{}:
+		writeMsgpackHeader(buf, len(v), 0x90, 15, 0xdc, false)
+		for _, item := range v {
+			if err := writeMsgpack(buf, item); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		writeMsgpackHeader(buf, len(v), 0x80, 15, 0xde, false)
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			writeMsgpackHeader(buf, len(k), 0xa0, 31, 0xd9, true)
+			buf.WriteString(k)
+			if err := writeMsgpack(buf, v[k]); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("msgpack: unsupported type %s", reflect.TypeOf(data))
+	}
+	return nil
+}
+
+// 写入长度，fixMax 以内使用 fix 格式，否则依次使用 8（仅 withByte 为 true 时）、16、32 位长度
+func writeMsgpackHeader(buf *bytes.Buffer, n int, fixCode byte, fixMax int, code byte, withByte bool) {
+	if n <= fixMax {
+		buf.WriteByte(fixCode | byte(n))
+		return
+	}
+	if !withByte {
+		code--
+	}
+	if withByte && n <= math.MaxUint8 {
+		buf.WriteByte(code)
+		buf.WriteByte(byte(n))
+	} else if n <= math.MaxUint16 {
+		buf.WriteByte(code + 1)
+		writeMsgpackUint(buf, uint64(n), 2)
+	} else {
+		buf.WriteByte(code + 2)
+		writeMsgpackUint(buf, uint64(n), 4)
+	}
+}
+
+func writeMsgpackInt(buf *bytes.Buffer, n int64) {
+	switch {
+	case n >= 0 && n <= 0x7f:
+		buf.WriteByte(byte(n))
+	case n < 0 && n >= -32:
+		buf.WriteByte(byte(int8(n)))
+	case n >= math.MinInt8 && n <= math.MaxInt8:
+		buf.WriteByte(0xd0)
+		buf.WriteByte(byte(int8(n)))
+	case n >= math.MinInt16 && n <= math.MaxInt16:
+		buf.WriteByte(0xd1)
+		writeMsgpackUint(buf, uint64(n), 2)
+	case n >= math.MinInt32 && n <= math.MaxInt32:
+		buf.WriteByte(0xd2)
+		writeMsgpackUint(buf, uint64(n), 4)
+	default:
+		buf.WriteByte(0xd3)
+		writeMsgpackUint(buf, uint64(n), 8)
+	}
+}
+
+func writeMsgpackFloat(buf *bytes.Buffer, f float64) {
+	buf.WriteByte(0xcb)
+	writeMsgpackUint(buf, math.Float64bits(f), 8)
+}
+
+func writeMsgpackUint(buf *bytes.Buffer, n uint64, size int) {
+	for i := size - 1; i >= 0; i-- {
+		buf.WriteByte(byte(n >> (uint(i) * 8)))
+	}
+}
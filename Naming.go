@@ -0,0 +1,323 @@
+package s
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// 输出字段的命名方式，使用 fieldNaming 配置，默认（为空时）与之前的版本一致，按 u.FixUpperCase 的规则只把首字母转为小写
+const (
+	NamingFixUpperCase = "fixUpperCase"
+	NamingLowerCamel   = "lowerCamel"
+	NamingSnake        = "snake"
+	NamingAsIs         = "asIs"
+)
+
+var jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+var textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+type namedField struct {
+	index     []int
+	name      string
+	omitEmpty bool
+}
+
+type namedFieldsKey struct {
+	t      reflect.Type
+	naming string
+}
+
+var namedFieldsCache = sync.Map{}
+
+// 按命名方式转换字段名
+func makeFieldName(name string) string {
+	switch Config.FieldNaming {
+	case NamingAsIs:
+		return name
+	case NamingSnake:
+		return toSnakeCase(name)
+	case NamingLowerCamel:
+		return toLowerCamel(name)
+	default:
+		return fixUpperCase(name)
+	}
+}
+
+// 与 u.FixUpperCase 一致，包含小写字母时只把首字母转为小写，URLPath => uRLPath，全大写的 ID 不转换
+func fixUpperCase(name string) string {
+	if name == "" || name[0] < 'A' || name[0] > 'Z' || strings.IndexFunc(name, func(r rune) bool { return r >= 'a' && r <= 'z' }) == -1 {
+		return name
+	}
+	return string(name[0]+32) + name[1:]
+}
+
+func isFixUpperCase() bool {
+	switch Config.FieldNaming {
+	case NamingLowerCamel, NamingSnake, NamingAsIs:
+		return false
+	}
+	return true
+}
+
+// 开头连续的大写字母整体转为小写，URLPath => urlPath、UserID => userID，全大写的字段名（如 ID）不转换
+func toLowerCamel(name string) string {
+	runes := []rune(name)
+	n := 0
+	for n < len(runes) && unicode.IsUpper(runes[n]) {
+		n++
+	}
+	if n == len(runes) {
+		return name
+	}
+	if n > 1 && unicode.IsLower(runes[n]) {
+		n--
+	}
+	for i := 0; i < n; i++ {
+		runes[i] = unicode.ToLower(runes[i])
+	}
+	return string(runes)
+}
+
+// 按单词边界加下划线，UserID => user_id、URLPath => url_path
+func toSnakeCase(name string) string {
+	runes := []rune(name)
+	buf := bytes.Buffer{}
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1]) || (unicode.IsUpper(runes[i-1]) && i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+				buf.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		buf.WriteRune(r)
+	}
+	return buf.String()
+}
+
+// Map 的键，默认和小驼峰时与之前一样只把首字母转为小写（全大写的键不转换），asIs 时原样输出
+func makeMapKey(key string) string {
+	switch Config.FieldNaming {
+	case NamingAsIs:
+		return key
+	case NamingSnake:
+		return toSnakeCase(key)
+	}
+	return fixUpperCase(key)
+}
+
+// 字段输出的名称，json 标签中指定的名称原样使用（默认的命名方式与之前一样转换首字母），标签为 - 时返回空
+func fieldOutputName(field reflect.StructField) (string, bool) {
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", false
+	}
+	name := tag
+	opts := ""
+	if pos := strings.IndexByte(tag, ','); pos >= 0 {
+		name = tag[0:pos]
+		opts = tag[pos:]
+	}
+	if name == "" {
+		name = makeFieldName(field.Name)
+	} else if isFixUpperCase() {
+		name = fixUpperCase(name)
+	}
+	return name, strings.Contains(opts, ",omitempty")
+}
+
+// 参数错误中的字段名，与输出的字段名一致，json:"-" 的字段按命名方式转换
+func fieldErrorName(field reflect.StructField) string {
+	if name, _ := fieldOutputName(field); name != "" {
+		return name
+	}
+	return makeFieldName(field.Name)
+}
+
+// 结构体要输出的字段，与 encoding/json 一样展开匿名结构体，外层的字段优先
+func getNamedFields(t reflect.Type) []namedField {
+	key := namedFieldsKey{t, Config.FieldNaming}
+	if cached, ok := namedFieldsCache.Load(key); ok {
+		return cached.([]namedField)
+	}
+
+	fields := make([]namedField, 0)
+	used := map[string]bool{}
+	var embedded [][]int
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		ft := f.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && ft.Kind() == reflect.Struct && strings.SplitN(f.Tag.Get("json"), ",", 2)[0] == "" {
+			if f.Tag.Get("json") != "-" {
+				embedded = append(embedded, []int{i})
+			}
+			continue
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		if name, omitEmpty := fieldOutputName(f); name != "" {
+			fields = append(fields, namedField{index: []int{i}, name: name, omitEmpty: omitEmpty})
+			used[name] = true
+		}
+	}
+
+	for _, index := range embedded {
+		ft := t.Field(index[0]).Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		for _, f := range getNamedFields(ft) {
+			if !used[f.name] {
+				fields = append(fields, namedField{index: append(append([]int{}, index...), f.index...), name: f.name, omitEmpty: f.omitEmpty})
+				used[f.name] = true
+			}
+		}
+	}
+
+	namedFieldsCache.Store(key, fields)
+	return fields
+}
+
+// 按顺序输出的对象
+type namedObject struct {
+	keys   []string
+	values []interface{}
+}
+
+func (o *namedObject) set(key string, value interface{}) {
+	for i, k := range o.keys {
+		if k == key {
+			o.values[i] = value
+			return
+		}
+	}
+	o.keys = append(o.keys, key)
+	o.values = append(o.values, value)
+}
+
+func (o *namedObject) MarshalJSON() ([]byte, error) {
+	buf := bytes.Buffer{}
+	buf.WriteByte('{')
+	for i, k := range o.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		keyBytes, _ := json.Marshal(k)
+		buf.Write(keyBytes)
+		buf.WriteByte(':')
+		valueBytes, err := json.Marshal(o.values[i])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(valueBytes)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// 转换为按命名方式输出的数据，Map 的键使用 makeMapKey 转换
+func makeNamedData(data interface{}) interface{} {
+	if data == nil {
+		return nil
+	}
+	return makeNamedValue(reflect.ValueOf(data))
+}
+
+func makeNamedValue(v reflect.Value) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+	t := v.Type()
+	if !needNaming(t) || t.Implements(jsonMarshalerType) || t.Implements(textMarshalerType) {
+		return v.Interface()
+	}
+	// 与 encoding/json 一样，可以取地址的值（数组的元素、指针指向的结构体的字段）使用指针上的 MarshalJSON、MarshalText
+	if v.CanAddr() && (reflect.PtrTo(t).Implements(jsonMarshalerType) || reflect.PtrTo(t).Implements(textMarshalerType)) {
+		return v.Addr().Interface()
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return makeNamedValue(v.Elem())
+	case reflect.Struct:
+		out := &namedObject{}
+		for _, f := range getNamedFields(t) {
+			fv, ok := fieldByIndex(v, f.index)
+			if !ok || !fv.CanInterface() || (f.omitEmpty && isEmptyValue(fv)) {
+				continue
+			}
+			out.set(f.name, makeNamedValue(fv))
+		}
+		return out
+	case reflect.Map:
+		if v.IsNil() {
+			return v.Interface()
+		}
+		out := reflect.MakeMap(reflect.MapOf(t.Key(), reflect.TypeOf((*interface{})(nil)).Elem()))
+		for _, mk := range v.MapKeys() {
+			mv := makeNamedValue(v.MapIndex(mk))
+			key := mk
+			if mk.Kind() == reflect.String {
+				key = reflect.ValueOf(makeMapKey(mk.String())).Convert(t.Key())
+			}
+			if mv == nil {
+				out.SetMapIndex(key, reflect.Zero(out.Type().Elem()))
+			} else {
+				out.SetMapIndex(key, reflect.ValueOf(mv))
+			}
+		}
+		return out.Interface()
+	case reflect.Slice, reflect.Array:
+		if t.Kind() == reflect.Slice && v.IsNil() {
+			return v.Interface()
+		}
+		out := make([]interface{}, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			out[i] = makeNamedValue(v.Index(i))
+		}
+		return out
+	}
+	return v.Interface()
+}
+
+// 只包含基础类型的数据不需要转换（Map 的键需要转换时除外）
+func needNaming(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Struct, reflect.Ptr, reflect.Interface:
+		return true
+	case reflect.Map:
+		return (t.Key().Kind() == reflect.String && Config.FieldNaming != NamingAsIs) || needNaming(t.Elem())
+	case reflect.Array:
+		return needNaming(t.Elem())
+	case reflect.Slice:
+		return t.Elem().Kind() != reflect.Uint8 && needNaming(t.Elem())
+	}
+	return false
+}
+
+// 匿名结构体指针为 nil 时跳过其中的字段
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 {
+			for v.Kind() == reflect.Ptr {
+				if v.IsNil() {
+					return reflect.Value{}, false
+				}
+				v = v.Elem()
+			}
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
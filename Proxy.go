@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"net/url"
 	"regexp"
+	"sort"
 	"strings"
 	"time"
 
@@ -17,6 +18,7 @@ import (
 )
 
 type proxyInfo struct {
+	host     *hostMatcher
 	matcher  *regexp.Regexp
 	fromPath string
 	toApp    string
@@ -25,6 +27,7 @@ type proxyInfo struct {
 
 var proxies = make(map[string]*proxyInfo, 0)
 var regexProxies = make([]*proxyInfo, 0)
+var hostProxies = make([]*proxyInfo, 0)
 var proxyBy func(*http.Request) (*string, *string, *map[string]string)
 
 // 跳转
@@ -42,12 +45,106 @@ func Proxy(path string, toApp, toPath string) {
 			logError(err.Error(), "expr", "^"+path+"$")
 		} else {
 			p.matcher = matcher
+		}
+	}
+	updateRoutes(func() {
+		if p.matcher != nil {
+			for _, pi := range regexProxies {
+				if pi.fromPath == path {
+					addRouteDuplicate("PROXY " + path)
+				}
+			}
 			regexProxies = append(regexProxies, p)
+		} else {
+			if proxies[path] != nil && (proxies[path].toApp != toApp || proxies[path].toPath != toPath) {
+				addRouteDuplicate("PROXY " + path)
+			}
+			proxies[path] = p
+		}
+	})
+}
+
+// 删除代理，可以在服务运行中调用
+func RemoveProxy(path string) {
+	updateRoutes(func() {
+		delete(proxies, path)
+		regexProxies = removeProxyInfo(regexProxies, "", path)
+	})
+}
+
+// 删除指定域名的代理
+func RemoveProxyWithHost(host, path string) {
+	updateRoutes(func() {
+		hostProxies = removeProxyInfo(hostProxies, strings.ToLower(host), path)
+	})
+}
+
+// 生成不包含指定代理的新列表，不修改正在使用的列表
+func removeProxyInfo(list []*proxyInfo, host, path string) []*proxyInfo {
+	newList := make([]*proxyInfo, 0, len(list))
+	for _, p := range list {
+		if p.fromPath == path && (p.host == nil || p.host.pattern == host) {
+			continue
+		}
+		newList = append(newList, p)
+	}
+	return newList
+}
+
+// 只对指定域名生效的代理，toApp、toPath 中可以使用 {name} 引用域名中的参数
+func ProxyWithHost(host, path string, toApp, toPath string) {
+	hm, err := makeHostMatcher(host)
+	if err != nil {
+		logError(err.Error(), "host", host, "path", path)
+		return
+	}
+	p := &proxyInfo{host: hm, fromPath: path, toApp: toApp, toPath: toPath}
+	if strings.Contains(path, "(") {
+		p.matcher, err = regexp.Compile("^" + path + "$")
+		if err != nil {
+			logError(err.Error(), "expr", "^"+path+"$")
+			return
 		}
 	}
-	if p.matcher == nil {
-		proxies[path] = p
+	updateRoutes(func() {
+		hostProxies = append(hostProxies, p)
+		sort.SliceStable(hostProxies, func(i, j int) bool {
+			return hostMatcherLess(hostProxies[i].host, hostProxies[j].host)
+		})
+	})
+}
+
+func findHostProxy(table *routeTable, request *http.Request, requestPath, queryString string) (*string, *string) {
+	host := getRequestHost(request)
+	for _, pi := range table.hostProxies {
+		hostArgs, ok := pi.host.match(host)
+		if !ok {
+			continue
+		}
+		toPath := ""
+		if pi.matcher == nil {
+			if pi.fromPath != requestPath {
+				continue
+			}
+			toPath = pi.toPath
+		} else {
+			finds := pi.matcher.FindAllStringSubmatch(requestPath, 20)
+			if len(finds) == 0 {
+				continue
+			}
+			toPath = pi.toPath
+			for i, partValue := range finds[0] {
+				toPath = strings.Replace(toPath, fmt.Sprintf("$%d", i), partValue, 10)
+			}
+			if queryString != "" {
+				toPath += queryString
+			}
+		}
+		toApp := replaceHostArgs(pi.toApp, hostArgs)
+		toPath = replaceHostArgs(toPath, hostArgs)
+		return &toApp, &toPath
 	}
+	return nil, nil
 }
 
 // 查找 Proxy
@@ -57,16 +154,22 @@ func findProxy(request *http.Request) (*string, *string) {
 	pos := strings.LastIndex(request.RequestURI, "?")
 	if pos != -1 {
 		requestPath = request.RequestURI[0:pos]
-		queryString = requestPath[pos:]
+		queryString = request.RequestURI[pos:]
 	} else {
 		requestPath = request.RequestURI
 	}
-	pi := proxies[requestPath]
+	table := getRouteTable()
+	if len(table.hostProxies) > 0 {
+		if toApp, toPath := findHostProxy(table, request, requestPath, queryString); toApp != nil {
+			return toApp, toPath
+		}
+	}
+	pi := table.proxies[requestPath]
 	if pi != nil {
 		return &pi.toApp, &pi.toPath
 	}
-	if len(regexProxies) > 0 {
-		for _, pi := range regexProxies {
+	if len(table.regexProxies) > 0 {
+		for _, pi := range table.regexProxies {
 			finds := pi.matcher.FindAllStringSubmatch(requestPath, 20)
 			if len(finds) > 0 {
 				toPath := pi.toPath
@@ -0,0 +1,95 @@
+package s
+
+import (
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// 服务返回的结果，可以设置状态码、响应头和 Cookie，Body 与直接返回的结果一样处理（后置过滤器、压缩、日志）
+type Result struct {
+	Status  int
+	Headers map[string]string
+	Cookies []*http.Cookie
+	Body    interface{}
+}
+
+// 跳转到指定的地址，status 一般为 301、302、303、307、308
+func Redirect(status int, location string) *Result {
+	return &Result{Status: status, Headers: map[string]string{"Location": location}, Body: ""}
+}
+
+// 返回 204，没有内容
+func NoContent() *Result {
+	return &Result{Status: http.StatusNoContent, Body: ""}
+}
+
+// 下载文件，按文件名的扩展名设置 Content-Type
+func Download(fileName string, content []byte) *Result {
+	contentType := mime.TypeByExtension(filepath.Ext(fileName))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	return &Result{
+		Headers: map[string]string{
+			"Content-Type":        contentType,
+			"Content-Disposition": makeContentDisposition(fileName),
+		},
+		Body: content,
+	}
+}
+
+// 下载本地文件，fileName 为空时使用原文件名，文件不存在时返回 404
+func DownloadFile(path, fileName string) *Result {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		logError(err.Error(), "path", path)
+		return &Result{Status: http.StatusNotFound, Body: NewHTTPError(http.StatusNotFound, "")}
+	}
+	if fileName == "" {
+		fileName = filepath.Base(path)
+	}
+	return Download(fileName, content)
+}
+
+// 文件名中有非 ASCII 字符时同时使用 filename*
+func makeContentDisposition(fileName string) string {
+	asciiName := strings.Map(func(r rune) rune {
+		if r < 32 || r > 126 || r == '"' || r == '\\' {
+			return '_'
+		}
+		return r
+	}, fileName)
+	disposition := `attachment; filename="` + asciiName + `"`
+	if asciiName != fileName {
+		disposition += "; filename*=UTF-8''" + strings.Replace(url.QueryEscape(fileName), "+", "%20", -1)
+	}
+	return disposition
+}
+
+// 取出服务返回的 Result，结果替换为 Body
+func takeResult(ctx *Context) {
+	switch r := ctx.Result.(type) {
+	case *Result:
+		if r != nil {
+			ctx.output = r
+			ctx.Result = r.Body
+		}
+	case Result:
+		ctx.output = &r
+		ctx.Result = r.Body
+	}
+}
+
+// 输出 Result 中的响应头和 Cookie
+func (r *Result) writeHeaders(response http.ResponseWriter) {
+	for k, v := range r.Headers {
+		response.Header().Set(k, v)
+	}
+	for _, cookie := range r.Cookies {
+		http.SetCookie(response, cookie)
+	}
+}
@@ -5,11 +5,13 @@ import (
 	"github.com/ssgo/log"
 	"net/http"
 	"regexp"
+	"sort"
 	"strings"
 	"time"
 )
 
 type rewriteInfo struct {
+	host    *hostMatcher
 	matcher *regexp.Regexp
 	//httpVersion int
 	fromPath string
@@ -19,6 +21,7 @@ type rewriteInfo struct {
 var rewrites = make(map[string]*rewriteInfo)
 var rewriteBy func(*http.Request) (string, bool)
 var regexRewrites = make([]*rewriteInfo, 0)
+var hostRewrites = make([]*rewriteInfo, 0)
 
 //var clientForRewrite1 *httpclient.ClientPool
 //var clientForRewrite2 *httpclient.ClientPool
@@ -40,17 +43,80 @@ func setRewrite(path string, toPath string) {
 			//log.Print("Rewrite Error	Compile	", err)
 		} else {
 			s.matcher = matcher
-			regexRewrites = append(regexRewrites, s)
 		}
 	}
-	if s.matcher == nil {
-		rewrites[path] = s
-	}
+	updateRoutes(func() {
+		if s.matcher != nil {
+			for _, ri := range regexRewrites {
+				if ri.fromPath == path {
+					addRouteDuplicate("REWRITE " + path)
+				}
+			}
+			regexRewrites = append(regexRewrites, s)
+		} else {
+			if rewrites[path] != nil && rewrites[path].toPath != toPath {
+				addRouteDuplicate("REWRITE " + path)
+			}
+			rewrites[path] = s
+		}
+	})
 }
 func Rewrite(path string, toPath string) {
 	setRewrite(path, toPath)
 }
 
+// 只对指定域名生效的跳转，toPath 中可以使用 {name} 引用域名中的参数
+func RewriteWithHost(host, path string, toPath string) {
+	hm, err := makeHostMatcher(host)
+	if err != nil {
+		logError(err.Error(), "host", host, "fromPath", path, "toPath", toPath)
+		return
+	}
+	ri := &rewriteInfo{host: hm, fromPath: path, toPath: toPath}
+	if strings.ContainsRune(path, '(') {
+		ri.matcher, err = regexp.Compile("^" + path + "$")
+		if err != nil {
+			logError(err.Error(), "host", host, "fromPath", path, "toPath", toPath)
+			return
+		}
+	}
+	updateRoutes(func() {
+		hostRewrites = append(hostRewrites, ri)
+		sort.SliceStable(hostRewrites, func(i, j int) bool {
+			return hostMatcherLess(hostRewrites[i].host, hostRewrites[j].host)
+		})
+	})
+}
+
+func findHostRewrite(table *routeTable, request *http.Request, requestPath string) *string {
+	host := getRequestHost(request)
+	for _, ri := range table.hostRewrites {
+		hostArgs, ok := ri.host.match(host)
+		if !ok {
+			continue
+		}
+		toPath := ""
+		if ri.matcher == nil {
+			if ri.fromPath != requestPath {
+				continue
+			}
+			toPath = ri.toPath
+		} else {
+			finds := ri.matcher.FindAllStringSubmatch(request.RequestURI, 20)
+			if len(finds) == 0 {
+				continue
+			}
+			toPath = ri.toPath
+			for i, partValue := range finds[0] {
+				toPath = strings.Replace(toPath, fmt.Sprintf("$%d", i), partValue, 10)
+			}
+		}
+		toPath = replaceHostArgs(toPath, hostArgs)
+		return &toPath
+	}
+	return nil
+}
+
 //func Rewrite1(path string, toPath string) {
 //	setRewrite(path, toPath, 1)
 //}
@@ -74,8 +140,12 @@ func processRewrite(request *http.Request, response *Response, headers *map[stri
 	var rewriteToPath *string
 	//var rewriteHttpVersion int
 	//var rewriteHeaders *map[string]string
-	ri := rewrites[requestPath]
-	if ri != nil {
+	table := getRouteTable()
+	if len(table.hostRewrites) > 0 {
+		rewriteToPath = findHostRewrite(table, request, requestPath)
+	}
+	ri := table.rewrites[requestPath]
+	if rewriteToPath == nil && ri != nil {
 		rewriteToPath = &ri.toPath
 		//rewriteHttpVersion = ri.httpVersion
 	}
@@ -87,8 +157,8 @@ func processRewrite(request *http.Request, response *Response, headers *map[stri
 			//rewriteHeaders = h
 		}
 	}
-	if rewriteToPath == nil && len(regexRewrites) > 0 {
-		for _, ri = range regexRewrites {
+	if rewriteToPath == nil && len(table.regexRewrites) > 0 {
+		for _, ri = range table.regexRewrites {
 			finds := ri.matcher.FindAllStringSubmatch(request.RequestURI, 20)
 			if len(finds) > 0 {
 				toPath := ri.toPath
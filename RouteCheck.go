@@ -0,0 +1,249 @@
+package s
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/ssgo/u"
+)
+
+// 路由检查发现的问题
+// duplicate：重复注册，先注册的被覆盖或永远不会被匹配到
+// conflict：同一层级中具体程度相同的参数段可能匹配同一个请求，只能按文本顺序决定，需要通过优先级区分
+// shadowed：能匹配的请求都会被优先级更高的服务匹配，永远不会被执行
+type routeIssue struct {
+	kind  string
+	route string
+	with  string
+}
+
+var routeDuplicates = make([]routeIssue, 0)
+
+// 路由树中的一个服务，segments 为从某一层级开始的路径段节点
+type routeEndpoint struct {
+	node      *routeNode
+	segments  []*routeNode
+	method    string
+	priority  int
+	name      string
+	websocket bool
+}
+
+// 记录启动前的重复注册，启动后重复注册作为运行中替换服务，不作为问题
+func addRouteDuplicate(route string) {
+	if !running {
+		routeDuplicates = append(routeDuplicates, routeIssue{kind: "duplicate", route: route, with: route})
+	}
+}
+
+func (node *routeNode) endpoints(segments []*routeNode, out *[]routeEndpoint) {
+	// 同一节点上指定方法的服务先于不限方法的服务
+	methods := make([]string, 0, len(node.services))
+	for method := range node.services {
+		if method != "" {
+			methods = append(methods, method)
+		}
+	}
+	sort.Strings(methods)
+	if node.services[""] != nil {
+		methods = append(methods, "")
+	}
+	for _, method := range methods {
+		s := node.services[method]
+		*out = append(*out, routeEndpoint{node: node, segments: segments, method: method, priority: s.priority, name: u.StringIf(method != "", method, "*") + " " + s.host + s.path})
+	}
+	if node.websocket != nil {
+		*out = append(*out, routeEndpoint{node: node, segments: segments, method: "GET", priority: node.websocket.priority, name: "WS " + node.websocket.path, websocket: true})
+	}
+	for _, child := range node.sortedChildren() {
+		child.endpoints(append(segments[0:len(segments):len(segments)], child), out)
+	}
+}
+
+// 按匹配顺序排列的子节点：静态段（按文本排序） > 参数段 > 通配段
+func (node *routeNode) sortedChildren() []*routeNode {
+	children := make([]*routeNode, 0, len(node.statics)+len(node.children)+1)
+	staticNames := make([]string, 0, len(node.statics))
+	for name := range node.statics {
+		staticNames = append(staticNames, name)
+	}
+	sort.Strings(staticNames)
+	for _, name := range staticNames {
+		children = append(children, node.statics[name])
+	}
+	children = append(children, node.children...)
+	if node.catchAll != nil {
+		children = append(children, node.catchAll)
+	}
+	return children
+}
+
+// 两组路径段是否可能匹配同一个请求
+func routeSegmentsOverlap(a, b []*routeNode) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i].isCatchAll || b[i].isCatchAll {
+			return true
+		}
+		if len(a[i].params) == 0 && len(b[i].params) == 0 && a[i].literal != b[i].literal {
+			return false
+		}
+	}
+	return len(a) == len(b)
+}
+
+// a 是否能匹配 b 能匹配的所有请求
+func routeSegmentsCover(a, b []*routeNode) bool {
+	for i := range a {
+		if a[i].isCatchAll && a[i].params[0].paramType == nil {
+			return i < len(b)
+		}
+		if i >= len(b) || b[i].isCatchAll {
+			return false
+		}
+		if len(a[i].params) == 0 {
+			if len(b[i].params) != 0 || a[i].literal != b[i].literal {
+				return false
+			}
+		} else if a[i].matcher == nil && a[i].params[0].paramType == nil {
+			// 无约束的参数段能匹配任意一个路径段
+		} else if a[i].pattern != b[i].pattern {
+			return false
+		}
+	}
+	return len(a) == len(b)
+}
+
+func methodOverlap(a, b string) bool {
+	return a == b || a == "" || b == ""
+}
+
+func (node *routeNode) conflicts(out *[]routeIssue) {
+	for i, a := range node.children {
+		for _, b := range node.children[i+1:] {
+			if compareRouteSegment(a, b) != 0 {
+				continue
+			}
+			aEndpoints := make([]routeEndpoint, 0)
+			bEndpoints := make([]routeEndpoint, 0)
+			a.endpoints([]*routeNode{}, &aEndpoints)
+			b.endpoints([]*routeNode{}, &bEndpoints)
+			for _, ae := range aEndpoints {
+				for _, be := range bEndpoints {
+					if ae.priority == be.priority && methodOverlap(ae.method, be.method) && routeSegmentsOverlap(ae.segments, be.segments) {
+						*out = append(*out, routeIssue{kind: "conflict", route: ae.name, with: be.name})
+					}
+				}
+			}
+		}
+	}
+	for _, child := range node.sortedChildren() {
+		child.conflicts(out)
+	}
+}
+
+func (node *routeNode) shadowed(out *[]routeIssue) {
+	endpoints := make([]routeEndpoint, 0)
+	node.endpoints([]*routeNode{}, &endpoints)
+	for _, b := range endpoints {
+		if b.websocket {
+			continue
+		}
+		for _, a := range endpoints {
+			if a.websocket || a.node == b.node || a.priority <= b.priority {
+				continue
+			}
+			if (a.method == b.method || a.method == "") && routeSegmentsCover(a.segments, b.segments) {
+				*out = append(*out, routeIssue{kind: "shadowed", route: b.name, with: a.name})
+				break
+			}
+		}
+	}
+}
+
+// 检查路由表中的问题，在启动时调用
+func checkRoutes() []routeIssue {
+	table := getRouteTable()
+	routesLock.Lock()
+	issues := append([]routeIssue{}, routeDuplicates...)
+	routesLock.Unlock()
+
+	routers := []*routeNode{table.router}
+	for _, hr := range table.hostRouters {
+		routers = append(routers, hr.router)
+	}
+	for _, router := range routers {
+		router.conflicts(&issues)
+		router.shadowed(&issues)
+	}
+	return issues
+}
+
+// 按匹配顺序输出生效的路由表：Rewrite > Proxy > 静态资源 > 服务，每个路由表中第一个能匹配请求的服务就是处理请求的服务
+func makeRouteReport() []string {
+	table := getRouteTable()
+	lines := make([]string, 0)
+
+	for _, ri := range table.hostRewrites {
+		lines = append(lines, fmt.Sprintf("REWRITE\t%s%s -> %s", ri.host.pattern, ri.fromPath, ri.toPath))
+	}
+	for _, path := range sortedKeys(table.rewrites) {
+		lines = append(lines, fmt.Sprintf("REWRITE\t%s -> %s", path, table.rewrites[path].toPath))
+	}
+	for _, ri := range table.regexRewrites {
+		lines = append(lines, fmt.Sprintf("REWRITE\t%s -> %s", ri.fromPath, ri.toPath))
+	}
+
+	for _, pi := range table.hostProxies {
+		lines = append(lines, fmt.Sprintf("PROXY\t%s%s -> %s:%s", pi.host.pattern, pi.fromPath, pi.toApp, pi.toPath))
+	}
+	for _, path := range sortedKeys(table.proxies) {
+		lines = append(lines, fmt.Sprintf("PROXY\t%s -> %s:%s", path, table.proxies[path].toApp, table.proxies[path].toPath))
+	}
+	for _, pi := range table.regexProxies {
+		lines = append(lines, fmt.Sprintf("PROXY\t%s -> %s:%s", pi.fromPath, pi.toApp, pi.toPath))
+	}
+
+	for _, si := range table.hostStatics {
+		lines = append(lines, fmt.Sprintf("STATIC\t%s%s -> %s", si.host.pattern, si.path, si.rootPath))
+	}
+	for _, path := range sortedKeys(table.statics) {
+		lines = append(lines, fmt.Sprintf("STATIC\t%s -> %s", path, *table.statics[path]))
+	}
+
+	routers := make([]*routeNode, 0)
+	for _, hr := range table.hostRouters {
+		routers = append(routers, hr.router)
+	}
+	routers = append(routers, table.router)
+	for _, router := range routers {
+		endpoints := make([]routeEndpoint, 0)
+		router.endpoints([]*routeNode{}, &endpoints)
+		// 匹配时保留优先级最高的服务，优先级相同时先找到的优先，按优先级稳定排序后第一个能匹配请求的就是生效的服务
+		sort.SliceStable(endpoints, func(i, j int) bool {
+			return endpoints[i].priority > endpoints[j].priority
+		})
+		for _, e := range endpoints {
+			if e.websocket {
+				lines = append(lines, fmt.Sprintf("WS\t%s\tpriority:%d", strings.TrimPrefix(e.name, "WS "), e.priority))
+			} else {
+				lines = append(lines, fmt.Sprintf("WEB\t%s\tpriority:%d", e.name, e.priority))
+			}
+		}
+	}
+
+	for _, issue := range checkRoutes() {
+		lines = append(lines, fmt.Sprintf("%s\t%s\t%s", strings.ToUpper(issue.kind), issue.route, issue.with))
+	}
+	return lines
+}
+
+func sortedKeys(m interface{}) []string {
+	keys := make([]string, 0)
+	for _, k := range reflect.ValueOf(m).MapKeys() {
+		keys = append(keys, k.String())
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,600 @@
+package s
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// 路由树，按路径段逐级匹配，匹配耗时只与路径长度相关，与注册的服务数量无关
+// 同一层级的匹配顺序固定为：静态段 > 参数段（越具体越优先） > 通配段，与注册顺序无关
+type routeNode struct {
+	pattern    string
+	literal    string
+	matcher    *regexp.Regexp
+	groups     []int
+	params     []*routeParam
+	statics    map[string]*routeNode
+	children   []*routeNode
+	catchAll   *routeNode
+	services   map[string]*webServiceType
+	websocket  *websocketServiceType
+	isCatchAll bool
+	// 子树中服务的最高优先级，用于匹配时跳过不可能胜出的分支
+	maxPriority int
+}
+
+// 路径参数，{name}、{name:type} 或 {name:正则}
+type routeParam struct {
+	name       string
+	constraint string
+	paramType  *routeParamType
+	checker    *regexp.Regexp
+}
+
+type routeParamType struct {
+	expr    string
+	convert func(string) (interface{}, error)
+}
+
+type routeArg struct {
+	name  string
+	value interface{}
+}
+
+// 匹配结果，优先级高的服务胜出，优先级相同时先匹配到的（更具体的）胜出
+type routeMatch struct {
+	service   *webServiceType
+	websocket *websocketServiceType
+	args      []routeArg
+	priority  int
+	found     bool
+}
+
+var routeParamTypes = map[string]*routeParamType{
+	"int": {expr: `[-+]?\d+`, convert: func(v string) (interface{}, error) {
+		return strconv.ParseInt(v, 10, 64)
+	}},
+	"uint": {expr: `\d+`, convert: func(v string) (interface{}, error) {
+		return strconv.ParseUint(v, 10, 64)
+	}},
+	"float": {expr: `[-+]?(?:\d+\.?\d*|\.\d+)`, convert: func(v string) (interface{}, error) {
+		return strconv.ParseFloat(v, 64)
+	}},
+	"bool": {expr: `true|false|1|0`, convert: func(v string) (interface{}, error) {
+		return strconv.ParseBool(v)
+	}},
+	"uuid":  {expr: `[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`},
+	"alpha": {expr: `[a-zA-Z]+`},
+}
+
+// 请求中使用的路由表，只读，注册或删除服务、Rewrite、Proxy、静态资源后重新生成，请求中不需要加锁
+type routeTable struct {
+	router        *routeNode
+	hostRouters   []*hostRouter
+	rewrites      map[string]*rewriteInfo
+	regexRewrites []*rewriteInfo
+	hostRewrites  []*rewriteInfo
+	proxies       map[string]*proxyInfo
+	regexProxies  []*proxyInfo
+	hostProxies   []*proxyInfo
+	statics       map[string]*string
+	hostStatics   []*hostStaticInfo
+}
+
+var routesLock = sync.Mutex{}
+var currentRoutes = atomic.Value{}
+
+// 修改注册信息，修改后的第一个请求会重新生成路由表，正在处理的请求继续使用旧的路由表
+func updateRoutes(update func()) {
+	routesLock.Lock()
+	defer routesLock.Unlock()
+	update()
+	currentRoutes.Store((*routeTable)(nil))
+}
+
+// 获取当前的路由表
+func getRouteTable() *routeTable {
+	if table, _ := currentRoutes.Load().(*routeTable); table != nil {
+		return table
+	}
+	routesLock.Lock()
+	defer routesLock.Unlock()
+	if table, _ := currentRoutes.Load().(*routeTable); table != nil {
+		return table
+	}
+	table := makeRouteTable()
+	currentRoutes.Store(table)
+	return table
+}
+
+func makeRouteTable() *routeTable {
+	table := &routeTable{
+		router:        newRouteNode(""),
+		hostRouters:   make([]*hostRouter, 0),
+		rewrites:      make(map[string]*rewriteInfo, len(rewrites)),
+		regexRewrites: append([]*rewriteInfo{}, regexRewrites...),
+		hostRewrites:  append([]*rewriteInfo{}, hostRewrites...),
+		proxies:       make(map[string]*proxyInfo, len(proxies)),
+		regexProxies:  append([]*proxyInfo{}, regexProxies...),
+		hostProxies:   append([]*proxyInfo{}, hostProxies...),
+		statics:       make(map[string]*string, len(statics)),
+		hostStatics:   append([]*hostStaticInfo{}, hostStatics...),
+	}
+	for k, v := range rewrites {
+		table.rewrites[k] = v
+	}
+	for k, v := range proxies {
+		table.proxies[k] = v
+	}
+	for k, v := range statics {
+		table.statics[k] = v
+	}
+	for _, s := range webServices {
+		if err := table.addWebServiceRoute(s); err != nil {
+			logError(err.Error(), "path", s.path, "method", s.method, "host", s.host)
+		}
+	}
+	for _, ws := range websocketServices {
+		if err := table.addWebsocketRoute(ws); err != nil {
+			logError(err.Error(), "path", ws.path)
+		}
+	}
+	table.router.updateMaxPriority()
+	for _, hr := range table.hostRouters {
+		hr.router.updateMaxPriority()
+	}
+	return table
+}
+
+func newRouteNode(pattern string) *routeNode {
+	return &routeNode{pattern: pattern, statics: map[string]*routeNode{}}
+}
+
+// 拆分路径，"/a/b" => ["a", "b"]，"/" => [""]
+func splitRoutePath(path string) []string {
+	if len(path) > 0 && path[0] == '/' {
+		path = path[1:]
+	}
+	return strings.Split(path, "/")
+}
+
+// 解析路径段，返回文本和参数交替的片段，参数定义中允许出现成对的 {}，例如 {code:[0-9]{4}}
+func parseRouteSegment(segment string) (literals []string, params []*routeParam, err error) {
+	literal := ""
+	for i := 0; i < len(segment); i++ {
+		if segment[i] != '{' {
+			literal += segment[i : i+1]
+			continue
+		}
+		depth := 0
+		end := -1
+		for j := i; j < len(segment); j++ {
+			if segment[j] == '{' {
+				depth++
+			} else if segment[j] == '}' {
+				depth--
+				if depth == 0 {
+					end = j
+					break
+				}
+			}
+		}
+		if end == -1 {
+			return nil, nil, fmt.Errorf("unclosed path arg in %s", segment)
+		}
+
+		param := &routeParam{name: segment[i+1 : end]}
+		if pos := strings.IndexByte(param.name, ':'); pos != -1 {
+			param.constraint = param.name[pos+1:]
+			param.name = param.name[0:pos]
+			param.paramType = routeParamTypes[param.constraint]
+			if param.paramType == nil {
+				param.paramType = &routeParamType{expr: param.constraint}
+			}
+			param.checker, err = regexp.Compile("^(?:" + param.paramType.expr + ")$")
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+		literals = append(literals, literal)
+		params = append(params, param)
+		literal = ""
+		i = end
+	}
+	literals = append(literals, literal)
+	return literals, params, nil
+}
+
+// 检查服务的域名和路径是否能加入路由树
+func checkRoutePath(host, path string) error {
+	if host != "" {
+		if _, err := makeHostMatcher(host); err != nil {
+			return err
+		}
+	}
+	_, err := newRouteNode("").makeNode(path)
+	return err
+}
+
+func (table *routeTable) addWebServiceRoute(s *webServiceType) error {
+	router, err := table.getHostRouter(s.host)
+	if err != nil {
+		return err
+	}
+	node, err := router.makeNode(s.path)
+	if err != nil {
+		return err
+	}
+	node.services[s.method] = s
+	return nil
+}
+
+func (table *routeTable) addWebsocketRoute(ws *websocketServiceType) error {
+	node, err := table.router.makeNode(ws.path)
+	if err != nil {
+		return err
+	}
+	node.websocket = ws
+	return nil
+}
+
+func (node *routeNode) makeNode(path string) (*routeNode, error) {
+	segments := splitRoutePath(path)
+	current := node
+	for i, segment := range segments {
+		var err error
+		current, err = current.child(segment, i == len(segments)-1)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if current.services == nil {
+		current.services = map[string]*webServiceType{}
+	}
+	return current, nil
+}
+
+// 查找或创建子节点
+func (node *routeNode) child(segment string, isLast bool) (*routeNode, error) {
+	literals, params, err := parseRouteSegment(segment)
+	if err != nil {
+		return nil, err
+	}
+	if len(params) == 0 {
+		child := node.statics[segment]
+		if child == nil {
+			child = newRouteNode(segment)
+			child.literal = segment
+			node.statics[segment] = child
+		}
+		return child, nil
+	}
+
+	// {name*} 通配剩余的所有路径段，只能出现在路径末尾
+	if len(params) == 1 && literals[0] == "" && literals[1] == "" && strings.HasSuffix(params[0].name, "*") {
+		if !isLast {
+			return nil, fmt.Errorf("catch-all path arg must be the last segment: %s", segment)
+		}
+		if node.catchAll == nil {
+			node.catchAll = newRouteNode(segment)
+			node.catchAll.isCatchAll = true
+			params[0].name = params[0].name[0 : len(params[0].name)-1]
+			node.catchAll.params = params
+		}
+		return node.catchAll, nil
+	}
+
+	for _, child := range node.children {
+		if child.pattern == segment {
+			return child, nil
+		}
+	}
+
+	child := newRouteNode(segment)
+	child.literal = strings.Join(literals, "")
+	child.params = params
+	if len(params) > 1 || literals[0] != "" || literals[1] != "" {
+		// 参数与文本混合的路径段，例如 {name}.png、{id:int}-{name}
+		expr := ""
+		for i, param := range params {
+			argExpr := ".*?"
+			if param.paramType != nil {
+				argExpr = param.paramType.expr
+			}
+			expr += regexp.QuoteMeta(literals[i]) + fmt.Sprintf("(?P<p%d>%s)", i, argExpr)
+		}
+		expr += regexp.QuoteMeta(literals[len(literals)-1])
+		child.matcher, err = regexp.Compile("^" + expr + "$")
+		if err != nil {
+			return nil, err
+		}
+		// 约束中可能包含分组，按名称定位每个参数的分组
+		child.groups = make([]int, len(params))
+		for groupIndex, groupName := range child.matcher.SubexpNames() {
+			for i := range params {
+				if groupName == fmt.Sprintf("p%d", i) {
+					child.groups[i] = groupIndex
+				}
+			}
+		}
+	}
+	node.children = append(node.children, child)
+	sort.SliceStable(node.children, func(i, j int) bool {
+		return routeSegmentLess(node.children[i], node.children[j])
+	})
+	return child, nil
+}
+
+// 参数段的优先级：固定文本越多越优先，其次有约束的参数越多越优先，再次内置类型的参数越多越优先，再次参数越少越优先，最后按文本排序保证结果稳定
+func routeSegmentLess(a, b *routeNode) bool {
+	if c := compareRouteSegment(a, b); c != 0 {
+		return c < 0
+	}
+	return a.pattern < b.pattern
+}
+
+// 比较参数段的具体程度，a 更具体时返回负数，无法区分时返回 0
+func compareRouteSegment(a, b *routeNode) int {
+	if len(a.literal) != len(b.literal) {
+		return len(b.literal) - len(a.literal)
+	}
+	aConstraints, aTypes := a.constraintsNum()
+	bConstraints, bTypes := b.constraintsNum()
+	if aConstraints != bConstraints {
+		return bConstraints - aConstraints
+	}
+	if aTypes != bTypes {
+		return bTypes - aTypes
+	}
+	return len(a.params) - len(b.params)
+}
+
+// 有约束的参数数量和其中使用内置类型的数量
+func (node *routeNode) constraintsNum() (constraints int, types int) {
+	for _, param := range node.params {
+		if param.paramType != nil {
+			constraints++
+			if routeParamTypes[param.constraint] != nil {
+				types++
+			}
+		}
+	}
+	return constraints, types
+}
+
+// 校验并转换参数值，不符合约束时返回 false
+func (param *routeParam) value(rawValue string) (interface{}, bool) {
+	value, err := url.QueryUnescape(rawValue)
+	if err != nil {
+		value = rawValue
+	}
+	if param.paramType == nil {
+		return value, true
+	}
+	if !param.checker.MatchString(value) {
+		return nil, false
+	}
+	if param.paramType.convert != nil {
+		converted, err := param.paramType.convert(value)
+		if err != nil {
+			return nil, false
+		}
+		return converted, true
+	}
+	return value, true
+}
+
+// 绑定参数段的值
+func (node *routeNode) bind(segment string, args *[]routeArg) bool {
+	if node.matcher == nil {
+		value, ok := node.params[0].value(segment)
+		if !ok {
+			return false
+		}
+		*args = append(*args, routeArg{name: node.params[0].name, value: value})
+		return true
+	}
+	finds := node.matcher.FindStringSubmatch(segment)
+	if finds == nil {
+		return false
+	}
+	for i, param := range node.params {
+		value, ok := param.value(finds[node.groups[i]])
+		if !ok {
+			return false
+		}
+		*args = append(*args, routeArg{name: param.name, value: value})
+	}
+	return true
+}
+
+func (node *routeNode) endpoint(method string) (*webServiceType, *websocketServiceType) {
+	if node.services != nil {
+		if s := node.services[method]; s != nil {
+			return s, nil
+		}
+		// 未注册 HEAD 时使用 GET 的服务处理，输出时不发送内容
+		if method == "HEAD" {
+			if s := node.services["GET"]; s != nil {
+				return s, nil
+			}
+		}
+		if s := node.services[""]; s != nil {
+			return s, nil
+		}
+	}
+	return nil, node.websocket
+}
+
+func (node *routeNode) updateMaxPriority() int {
+	node.maxPriority = math.MinInt32
+	for _, s := range node.services {
+		if s.priority > node.maxPriority {
+			node.maxPriority = s.priority
+		}
+	}
+	if node.websocket != nil && node.websocket.priority > node.maxPriority {
+		node.maxPriority = node.websocket.priority
+	}
+	for _, child := range node.sortedChildren() {
+		if priority := child.updateMaxPriority(); priority > node.maxPriority {
+			node.maxPriority = priority
+		}
+	}
+	return node.maxPriority
+}
+
+// 优先级更高的服务才能替换已经匹配到的服务
+func (match *routeMatch) accept(priority int) bool {
+	return !match.found || priority > match.priority
+}
+
+func (node *routeNode) offer(method string, args []routeArg, match *routeMatch) {
+	s, ws := node.endpoint(method)
+	var priority int
+	if s != nil {
+		priority = s.priority
+	} else if ws != nil {
+		priority = ws.priority
+	} else {
+		return
+	}
+	if match.accept(priority) {
+		match.service = s
+		match.websocket = ws
+		match.args = append(match.args[0:0], args...)
+		match.priority = priority
+		match.found = true
+	}
+}
+
+// 按 静态段 > 参数段 > 通配段 的顺序查找所有匹配的服务，保留优先级最高的一个
+func (node *routeNode) find(segments []string, method string, args *[]routeArg, match *routeMatch) {
+	if len(segments) == 0 {
+		node.offer(method, *args, match)
+		return
+	}
+
+	segment := segments[0]
+	if child := node.statics[segment]; child != nil && match.accept(child.maxPriority) {
+		child.find(segments[1:], method, args, match)
+	}
+
+	argsNum := len(*args)
+	for _, child := range node.children {
+		if !match.accept(child.maxPriority) {
+			continue
+		}
+		if child.bind(segment, args) {
+			child.find(segments[1:], method, args, match)
+		}
+		*args = (*args)[0:argsNum]
+	}
+
+	if node.catchAll != nil && match.accept(node.catchAll.maxPriority) {
+		if value, ok := node.catchAll.params[0].value(strings.Join(segments, "/")); ok {
+			*args = append(*args, routeArg{name: node.catchAll.params[0].name, value: value})
+			node.catchAll.offer(method, *args, match)
+			*args = (*args)[0:argsNum]
+		}
+	}
+}
+
+// 匹配服务，域名和路径中的参数存入 args，先匹配指定了域名的服务
+func matchRoute(host, method, requestPath string, args map[string]interface{}) (*webServiceType, *websocketServiceType) {
+	table := getRouteTable()
+	segments := splitRoutePath(requestPath)
+	foundArgs := make([]routeArg, 0)
+	for _, hr := range table.hostRouters {
+		hostArgs, ok := hr.host.match(host)
+		if !ok {
+			continue
+		}
+		match := routeMatch{}
+		hr.router.find(segments, method, &foundArgs, &match)
+		if match.found {
+			for k, v := range hostArgs {
+				args[k] = v
+			}
+			for _, arg := range match.args {
+				args[arg.name] = arg.value
+			}
+			return match.service, match.websocket
+		}
+		foundArgs = foundArgs[0:0]
+	}
+
+	match := routeMatch{}
+	table.router.find(segments, method, &foundArgs, &match)
+	if !match.found {
+		return nil, nil
+	}
+	for _, arg := range match.args {
+		args[arg.name] = arg.value
+	}
+	return match.service, match.websocket
+}
+
+func (node *routeNode) collectMethods(segments []string, methods map[string]bool) {
+	if len(segments) == 0 {
+		for method := range node.services {
+			methods[method] = true
+		}
+		if node.websocket != nil {
+			methods["GET"] = true
+		}
+		return
+	}
+
+	segment := segments[0]
+	if child := node.statics[segment]; child != nil {
+		child.collectMethods(segments[1:], methods)
+	}
+
+	args := make([]routeArg, 0)
+	for _, child := range node.children {
+		if child.bind(segment, &args) {
+			child.collectMethods(segments[1:], methods)
+		}
+		args = args[0:0]
+	}
+
+	if node.catchAll != nil {
+		if _, ok := node.catchAll.params[0].value(strings.Join(segments, "/")); ok {
+			node.catchAll.collectMethods(nil, methods)
+		}
+	}
+}
+
+// 路径上注册的所有请求方法，用于 405 和 OPTIONS 的 Allow 头，没有匹配的路径时返回空
+func allowedMethods(host, requestPath string) []string {
+	table := getRouteTable()
+	segments := splitRoutePath(requestPath)
+	methods := map[string]bool{}
+	for _, hr := range table.hostRouters {
+		if _, ok := hr.host.match(host); ok {
+			hr.router.collectMethods(segments, methods)
+		}
+	}
+	table.router.collectMethods(segments, methods)
+	if len(methods) == 0 {
+		return nil
+	}
+	if methods["GET"] {
+		methods["HEAD"] = true
+	}
+	methods["OPTIONS"] = true
+	list := make([]string, 0, len(methods))
+	for method := range methods {
+		list = append(list, method)
+	}
+	sort.Strings(list)
+	return list
+}
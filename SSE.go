@@ -0,0 +1,200 @@
+package s
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SSE 的事件，Data 为 string 或 []byte 时直接输出（多行时拆分为多个 data:），其他类型输出为 JSON
+type SSEEvent struct {
+	Id    string
+	Event string
+	Data  interface{}
+	Retry int
+}
+
+// 发送 SSE 事件，在服务中使用 *s.SSESender 参数获得
+type SSESender struct {
+	writer      http.ResponseWriter
+	lastEventId string
+	ctx         context.Context
+	cancel      context.CancelFunc
+	lock        sync.Mutex
+	events      int
+	err         error
+}
+
+var sseSenders = map[*SSESender]bool{}
+var sseSendersLock = sync.Mutex{}
+
+var errSSEClosed = fmt.Errorf("sse stream closed")
+
+// 注册 SSE 服务，服务需要 *s.SSESender 参数，服务返回后连接结束
+func RegisterSSE(authLevel int, path string, serviceFunc interface{}) *ServiceRegister {
+	sr := Restful(authLevel, "GET", path, serviceFunc)
+	if sr.service != nil && sr.service.sseIndex < 0 {
+		logError("sse service requires *s.SSESender parameter", "path", path)
+	}
+	return sr
+}
+
+// 设置 SSE 服务发送心跳的间隔时间（毫秒）
+func (sr *ServiceRegister) SetHeartbeat(interval int) *ServiceRegister {
+	if sr.service != nil {
+		sr.service.heartbeat = interval
+	}
+	return sr
+}
+
+func newSSESender(service *webServiceType, request *http.Request, writer http.ResponseWriter) *SSESender {
+	sender := &SSESender{writer: writer, lastEventId: request.Header.Get("Last-Event-ID")}
+	sender.ctx, sender.cancel = context.WithCancel(request.Context())
+
+	header := writer.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("X-Accel-Buffering", "no")
+	if request.ProtoMajor == 1 {
+		header.Set("Connection", "keep-alive")
+	}
+	writer.WriteHeader(http.StatusOK)
+	sender.flush()
+
+	sseSendersLock.Lock()
+	sseSenders[sender] = true
+	sseSendersLock.Unlock()
+
+	interval := service.heartbeat
+	if interval <= 0 {
+		interval = Config.SseHeartbeat
+	}
+	if interval > 0 {
+		go sender.keepAlive(time.Duration(interval) * time.Millisecond)
+	}
+	return sender
+}
+
+// 客户端重连时带来的最后一个事件编号
+func (sender *SSESender) LastEventId() string {
+	return sender.lastEventId
+}
+
+// 客户端断开或服务停止时关闭
+func (sender *SSESender) Done() <-chan struct{} {
+	return sender.ctx.Done()
+}
+
+// 发送事件，event 为空时客户端作为 message 事件处理
+func (sender *SSESender) Send(event string, data interface{}) error {
+	return sender.SendEvent(SSEEvent{Event: event, Data: data})
+}
+
+// 发送完整的事件
+func (sender *SSESender) SendEvent(event SSEEvent) error {
+	buf := strings.Builder{}
+	if event.Id != "" {
+		buf.WriteString("id: " + removeLineBreaks(event.Id) + "\n")
+	}
+	if event.Event != "" {
+		buf.WriteString("event: " + removeLineBreaks(event.Event) + "\n")
+	}
+	if event.Retry > 0 {
+		buf.WriteString("retry: " + strconv.Itoa(event.Retry) + "\n")
+	}
+	var data string
+	switch v := event.Data.(type) {
+	case string:
+		data = v
+	case []byte:
+		data = string(v)
+	default:
+		data = string(makeBytesResult(v))
+	}
+	for _, line := range splitLines(data) {
+		buf.WriteString("data: " + line + "\n")
+	}
+	buf.WriteString("\n")
+
+	err := sender.write(buf.String())
+	if err == nil {
+		sender.lock.Lock()
+		sender.events++
+		sender.lock.Unlock()
+	}
+	return err
+}
+
+func (sender *SSESender) write(s string) error {
+	sender.lock.Lock()
+	defer sender.lock.Unlock()
+	if sender.ctx.Err() != nil {
+		return errSSEClosed
+	}
+	if _, err := sender.writer.Write([]byte(s)); err != nil {
+		sender.cancel()
+		return err
+	}
+	sender.flush()
+	return nil
+}
+
+func (sender *SSESender) flush() {
+	if flusher, ok := sender.writer.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// 定时发送注释行，保持连接并及时发现断开的客户端
+func (sender *SSESender) keepAlive(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if sender.write(": ping\n\n") != nil {
+				return
+			}
+		case <-sender.ctx.Done():
+			return
+		}
+	}
+}
+
+// 服务返回后结束
+func (sender *SSESender) close() {
+	sender.lock.Lock()
+	sender.cancel()
+	sender.lock.Unlock()
+	sseSendersLock.Lock()
+	delete(sseSenders, sender)
+	sseSendersLock.Unlock()
+}
+
+// 停止服务时通知所有 SSE 服务结束
+func closeSSESenders() {
+	sseSendersLock.Lock()
+	for sender := range sseSenders {
+		sender.cancel()
+	}
+	sseSendersLock.Unlock()
+}
+
+func countSSESenders() int {
+	sseSendersLock.Lock()
+	defer sseSendersLock.Unlock()
+	return len(sseSenders)
+}
+
+// 按 \r\n、\r、\n 拆分多行数据
+func splitLines(s string) []string {
+	return strings.Split(strings.Replace(strings.Replace(s, "\r\n", "\n", -1), "\r", "\n", -1), "\n")
+}
+
+func removeLineBreaks(s string) string {
+	return strings.Replace(strings.Replace(s, "\r", "", -1), "\n", "", -1)
+}
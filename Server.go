@@ -45,9 +45,23 @@ type serviceConfig struct {
 	AccessTokens                  map[string]*int
 	RewriteTimeout                int
 	AcceptXRealIpWithoutRequestId bool
+	Cors                          CorsConfig
+	FailOnRouteIssues             bool
+	UploadMemorySize              int
+	UploadMaxSize                 int
+	MaxBodySize                   int
+	MaxJsonDepth                  int
+	ServiceTimeout                int
+	SseHeartbeat                  int
+	FieldNaming                   string
 }
 
-var Config = serviceConfig{}
+var Config = makeDefaultConfig()
+
+// 默认的配置，MaxBodySize、MaxJsonDepth 为 0 时不限制，未设置（-1）时在 Init 中使用默认值
+func makeDefaultConfig() serviceConfig {
+	return serviceConfig{MaxBodySize: -1, MaxJsonDepth: -1}
+}
 
 var accessTokens = map[string]*int{}
 
@@ -205,6 +219,26 @@ func Init() {
 		Config.CompressMaxSize = 4096000
 	}
 
+	if Config.UploadMemorySize <= 0 {
+		Config.UploadMemorySize = 8388608
+	}
+
+	if Config.UploadMaxSize <= 0 {
+		Config.UploadMaxSize = 104857600
+	}
+
+	if Config.SseHeartbeat <= 0 {
+		Config.SseHeartbeat = 15000
+	}
+
+	if Config.MaxBodySize < 0 {
+		Config.MaxBodySize = 10485760
+	}
+
+	if Config.MaxJsonDepth < 0 {
+		Config.MaxJsonDepth = 100
+	}
+
 	if Config.RewriteTimeout <= 0 {
 		Config.RewriteTimeout = 10000
 	}
@@ -255,6 +289,10 @@ func Init() {
 		}
 	}
 
+	if Config.Cors.AllowOrigins != "" {
+		globalCors = makeCorsPolicy(Config.Cors)
+	}
+
 	serverAddr = Config.Listen
 }
 
@@ -264,6 +302,13 @@ func Start() {
 
 func start(as *AsyncServer) {
 	// document must after registers
+	if inRoutesMode {
+		for _, line := range makeRouteReport() {
+			fmt.Println(line)
+		}
+		os.Exit(0)
+	}
+
 	if inDocumentMode {
 		if len(os.Args) >= 4 {
 			makeDockment(os.Args[2], os.Args[3])
@@ -297,6 +342,20 @@ func start(as *AsyncServer) {
 
 	logInfo("starting")
 
+	// 报告重复、无法区分和永远不会被匹配到的服务
+	routeIssues := checkRoutes()
+	for _, issue := range routeIssues {
+		logError("route "+issue.kind, "route", issue.route, "with", issue.with)
+	}
+	if len(routeIssues) > 0 && Config.FailOnRouteIssues {
+		logError("failed to start because of route issues")
+		running = false
+		if as != nil {
+			as.startChan <- false
+		}
+		return
+	}
+
 	rh := routeHandler{}
 	srv := &http.Server{
 		Addr:    Config.Listen,
@@ -25,7 +25,7 @@ import (
 
 func ResetAllSets() {
 	config.ResetConfigEnv()
-	Config = serviceConfig{}
+	Config = makeDefaultConfig()
 	inited = false
 
 	rewrites = make(map[string]*rewriteInfo)
@@ -33,18 +33,27 @@ func ResetAllSets() {
 	proxies = make(map[string]*proxyInfo, 0)
 	regexProxies = make([]*proxyInfo, 0)
 	statics = make(map[string]*string)
+	hostStatics = make([]*hostStaticInfo, 0)
+	hostRewrites = make([]*rewriteInfo, 0)
+	hostProxies = make([]*proxyInfo, 0)
 	sessionKey = ""
 	sessionCreator = nil
-	sessionObjects = map[*http.Request]map[reflect.Type]interface{}{}
 	injectObjects = map[reflect.Type]interface{}{}
+	injectProviders = map[reflect.Type]*injectProvider{}
 
 	webServices = make(map[string]*webServiceType)
-	regexWebServices = make([]*webServiceType, 0)
 	inFilters = make([]func(*map[string]interface{}, *http.Request, *http.ResponseWriter) interface{}, 0)
 	outFilters = make([]func(*map[string]interface{}, *http.Request, *http.ResponseWriter, interface{}) (interface{}, bool), 0)
+	middlewares = make([]func(*Context, func()), 0)
+	globalCors = nil
+	codecs = makeDefaultCodecs()
+	defaultFormats = nil
+	errorMapper = nil
+	resetConverters()
 
 	websocketServices = make(map[string]*websocketServiceType)
-	regexWebsocketServices = make([]*websocketServiceType, 0)
+	routeDuplicates = make([]routeIssue, 0)
+	currentRoutes.Store((*routeTable)(nil))
 	webAuthChecker = nil
 	webSocketActionAuthChecker = nil
 }
@@ -64,6 +64,7 @@ func (si *serviceInfoType) load() {
 
 var serviceInfo serviceInfoType
 var inDocumentMode = false
+var inRoutesMode = false
 
 func init() {
 	// 不切换方便开发，生产环境注意路径，尽量使用绝对路径
@@ -88,6 +89,8 @@ func init() {
 			os.Exit(0)
 		case "doc":
 			inDocumentMode = true
+		case "routes":
+			inRoutesMode = true
 		case "check", "c":
 			checkProcess()
 			os.Exit(0)
@@ -5,13 +5,51 @@ import (
 	"github.com/ssgo/log"
 	"net/http"
 	"os"
+	"sort"
 	"strings"
 	"time"
 )
 
 var statics = make(map[string]*string)
 
+type hostStaticInfo struct {
+	host     *hostMatcher
+	path     string
+	rootPath string
+}
+
+var hostStatics = make([]*hostStaticInfo, 0)
+
 func Static(path, rootPath string) {
+	rootPath = makeStaticRootPath(rootPath)
+	updateRoutes(func() {
+		if statics[path] != nil && *statics[path] != rootPath {
+			addRouteDuplicate("STATIC " + path)
+		}
+		statics[path] = &rootPath
+	})
+}
+
+// 只响应指定域名的静态资源
+func StaticWithHost(host, path, rootPath string) {
+	hm, err := makeHostMatcher(host)
+	if err != nil {
+		logError(err.Error(), "host", host, "path", path)
+		return
+	}
+	si := &hostStaticInfo{host: hm, path: path, rootPath: makeStaticRootPath(rootPath)}
+	updateRoutes(func() {
+		hostStatics = append(hostStatics, si)
+		sort.SliceStable(hostStatics, func(i, j int) bool {
+			if hostStatics[i].host.pattern != hostStatics[j].host.pattern {
+				return hostMatcherLess(hostStatics[i].host, hostStatics[j].host)
+			}
+			return len(hostStatics[i].path) > len(hostStatics[j].path)
+		})
+	})
+}
+
+func makeStaticRootPath(rootPath string) string {
 	rootPath = strings.ReplaceAll(rootPath, "\\", "/")
 	if rootPath[0] != '/' {
 		pos := strings.LastIndexByte(os.Args[0], '/')
@@ -25,8 +63,7 @@ func Static(path, rootPath string) {
 			}
 		}
 	}
-	rootPath = margePath(rootPath)
-	statics[path] = &rootPath
+	return margePath(rootPath)
 }
 
 func margePath(path string) string {
@@ -51,13 +88,28 @@ func margePath(path string) string {
 }
 
 func processStatic(requestPath string, request *http.Request, response *Response, headers *map[string]string, startTime *time.Time, requestLogger *log.Logger) bool {
-	if len(statics) == 0 {
+	table := getRouteTable()
+	if len(table.statics) == 0 && len(table.hostStatics) == 0 {
 		return false
 	}
 
-	rootPath := statics[requestPath]
+	var rootPath *string
+	if len(table.hostStatics) > 0 {
+		host := getRequestHost(request)
+		for _, si := range table.hostStatics {
+			if _, ok := si.host.match(host); ok && strings.HasPrefix(requestPath, si.path) {
+				rootPath = &si.rootPath
+				requestPath = requestPath[len(strings.TrimRight(si.path, "/")):]
+				break
+			}
+		}
+	}
+
+	if rootPath == nil {
+		rootPath = table.statics[requestPath]
+	}
 	if rootPath == nil {
-		for p1, p2 := range statics {
+		for p1, p2 := range table.statics {
 			if strings.HasPrefix(requestPath, p1) {
 				rootPath = p2
 				requestPath = requestPath[len(p1):]
@@ -0,0 +1,201 @@
+package s
+
+import (
+	"compress/gzip"
+	"github.com/ssgo/u"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+const ndjsonType = "application/x-ndjson"
+
+// 按行输出 JSON，数组的每一项一行
+func encodeNdjson(data interface{}) ([]byte, error) {
+	v := reflect.ValueOf(data)
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return append(makeBytesResult(data), '\n'), nil
+	}
+	out := make([]byte, 0)
+	for i := 0; i < v.Len(); i++ {
+		out = append(out, makeBytesResult(v.Index(i).Interface())...)
+		out = append(out, '\n')
+	}
+	return out, nil
+}
+
+// 是否为需要流式输出的结果：io.Reader、<-chan T、func(yield func(T) bool)
+func isStreamResult(result interface{}) bool {
+	if _, ok := result.(io.Reader); ok {
+		return true
+	}
+	t := reflect.TypeOf(result)
+	switch t.Kind() {
+	case reflect.Chan:
+		return t.ChanDir()&reflect.RecvDir != 0
+	case reflect.Func:
+		if t.NumIn() != 1 || t.NumOut() != 0 {
+			return false
+		}
+		yieldType := t.In(0)
+		return yieldType.Kind() == reflect.Func && yieldType.NumIn() == 1 && yieldType.NumOut() == 1 && yieldType.Out(0).Kind() == reflect.Bool
+	}
+	return false
+}
+
+// 记录输出的字节数（压缩前）
+type streamWriter struct {
+	writer io.Writer
+	outLen int
+	err    error
+}
+
+func (sw *streamWriter) Write(data []byte) (int, error) {
+	if sw.err != nil {
+		return 0, sw.err
+	}
+	n, err := sw.writer.Write(data)
+	sw.outLen += n
+	sw.err = err
+	return n, err
+}
+
+// 边生成边输出，不设置 Content-Length（使用 chunked），开启压缩时使用流式的 gzip
+// 对象的序列按 JSON 数组输出，Accept 为 application/x-ndjson 时每行输出一个对象
+func writeStream(ctx *Context, result interface{}) {
+	response := ctx.writer
+	request := ctx.Request
+	reader, isReader := result.(io.Reader)
+	if closer, ok := result.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	ndjson := !isReader && ctx.codec != nil && ctx.codec.mediaType == ndjsonType
+	if response.Header().Get("Content-Type") == "" {
+		if isReader {
+			response.Header().Set("Content-Type", "application/octet-stream")
+		} else if ndjson {
+			response.Header().Set("Content-Type", ndjsonType)
+		} else {
+			response.Header().Set("Content-Type", "application/json")
+		}
+	}
+	response.Header().Del("Content-Length")
+
+	out := &streamWriter{writer: response}
+	var zipWriter *gzip.Writer
+	if Config.Compress && request.Method != "HEAD" && strings.Contains(request.Header.Get("Accept-Encoding"), "gzip") {
+		if w, err := gzip.NewWriterLevel(response, 1); err == nil {
+			response.Header().Set("Content-Encoding", "gzip")
+			zipWriter = w
+			out.writer = zipWriter
+		}
+	}
+	if ctx.output != nil && ctx.output.Status != 0 {
+		response.WriteHeader(ctx.output.Status)
+	}
+	flush := func() {
+		if zipWriter != nil {
+			_ = zipWriter.Flush()
+		}
+		if flusher, ok := response.(http.Flusher); ok {
+			flusher.Flush()
+		}
+	}
+
+	items := 0
+	if request.Method != "HEAD" {
+		if isReader {
+			buf := make([]byte, 32768)
+			for out.err == nil {
+				n, err := reader.Read(buf)
+				if n > 0 {
+					_, _ = out.Write(buf[0:n])
+					flush()
+				}
+				if err != nil {
+					if err != io.EOF {
+						logError(err.Error(), "wrote", out.outLen)
+					}
+					break
+				}
+			}
+		} else {
+			send := func(item interface{}) bool {
+				if ndjson {
+					_, _ = out.Write(append(makeBytesResult(item), '\n'))
+				} else {
+					_, _ = out.Write([]byte(u.StringIf(items == 0, "[", ",")))
+					_, _ = out.Write(makeBytesResult(item))
+				}
+				items++
+				flush()
+				return out.err == nil && request.Context().Err() == nil
+			}
+			sendItems(reflect.ValueOf(result), request, send)
+			if !ndjson && out.err == nil {
+				_, _ = out.Write([]byte(u.StringIf(items == 0, "[]", "]")))
+			}
+		}
+		if out.err != nil {
+			logError(out.err.Error(), "wrote", out.outLen)
+		}
+	} else if !isReader {
+		// HEAD 请求不输出内容，写入 channel 的 goroutine 不能一直阻塞
+		drainChannel(reflect.ValueOf(result))
+	}
+	if zipWriter != nil {
+		_ = zipWriter.Close()
+	}
+	flush()
+
+	extraInfo := Map{"stream": true}
+	if !isReader {
+		extraInfo["items"] = items
+	}
+	writeLog(ctx.Logger, "ACCESS", nil, out.outLen, request, ctx.Response, &ctx.Args, &ctx.logHeaders, &ctx.StartTime, ctx.authLevel, extraInfo)
+}
+
+// 从 channel 或迭代函数中逐个读取，send 返回 false 或请求结束时停止
+func sendItems(v reflect.Value, request *http.Request, send func(interface{}) bool) {
+	if v.Kind() == reflect.Func {
+		yield := reflect.MakeFunc(v.Type().In(0), func(args []reflect.Value) []reflect.Value {
+			return []reflect.Value{reflect.ValueOf(send(args[0].Interface()))}
+		})
+		v.Call([]reflect.Value{yield})
+		return
+	}
+
+	cases := []reflect.SelectCase{
+		{Dir: reflect.SelectRecv, Chan: v},
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(request.Context().Done())},
+	}
+	for {
+		chosen, item, ok := reflect.Select(cases)
+		if !ok && chosen == 0 {
+			return
+		}
+		if chosen != 0 || !send(item.Interface()) {
+			drainChannel(v)
+			return
+		}
+	}
+}
+
+// 停止输出后在后台读完 channel 中剩余的数据，直到 channel 被关闭
+func drainChannel(v reflect.Value) {
+	if v.Kind() != reflect.Chan {
+		return
+	}
+	go func() {
+		for {
+			if _, ok := v.Recv(); !ok {
+				return
+			}
+		}
+	}()
+}
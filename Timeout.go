@@ -0,0 +1,151 @@
+package s
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/ssgo/standard"
+)
+
+// 调用其他服务时传递剩余的处理时间（毫秒），被调用的服务以此缩短自己的超时时间
+const timeoutHeader = "X-Timeout"
+
+var contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+
+// 超时后仍在运行的服务，保存在服务的 context 中
+type runningService struct {
+	done chan struct{}
+}
+
+type runningServiceKey struct{}
+
+// 设置服务的超时时间（毫秒），超时后立即返回 504，服务可以通过 context.Context 参数得知
+func (sr *ServiceRegister) SetTimeout(timeout int) *ServiceRegister {
+	if sr.service != nil {
+		sr.service.timeout = timeout
+	}
+	return sr
+}
+
+// 生成服务使用的 context，来自 request.Context()，并按服务、配置和上游传递的时间设置超时
+// SSE 服务不设置超时
+func makeServiceContext(service *webServiceType, request *http.Request) (context.Context, context.CancelFunc) {
+	if service.sseIndex >= 0 {
+		return request.Context(), nil
+	}
+	timeout := service.timeout
+	if timeout <= 0 {
+		timeout = Config.ServiceTimeout
+	}
+	// 只接受其他服务（discover.Caller 会带上 X-From-App）传递的剩余时间，并且只能缩短超时时间
+	if request.Header.Get(standard.DiscoverHeaderFromApp) != "" {
+		if upstreamTimeout, err := strconv.Atoi(request.Header.Get(timeoutHeader)); err == nil && upstreamTimeout > 0 && (timeout <= 0 || upstreamTimeout < timeout) {
+			timeout = upstreamTimeout
+		}
+	}
+	if timeout <= 0 {
+		return request.Context(), nil
+	}
+	return context.WithTimeout(context.WithValue(request.Context(), runningServiceKey{}, &runningService{}), time.Duration(timeout)*time.Millisecond)
+}
+
+// 服务返回后执行，超时后服务仍在运行时在服务返回后执行，用于释放服务使用的资源（上传的临时文件等）
+func afterService(ctx context.Context, f func()) {
+	if running, ok := ctx.Value(runningServiceKey{}).(*runningService); ok && running.done != nil {
+		go func() {
+			<-running.done
+			f()
+		}()
+		return
+	}
+	f()
+}
+
+// 给 discover.Caller 使用的请求，使用服务的 context，并带上剩余的处理时间
+func makeCallerRequest(request *http.Request, ctx context.Context) *http.Request {
+	callerRequest := request.WithContext(ctx)
+	remains := remainingTimeout(ctx)
+	if remains == 0 {
+		return callerRequest
+	}
+	callerRequest.Header = make(http.Header, len(request.Header)+1)
+	for k, v := range request.Header {
+		callerRequest.Header[k] = v
+	}
+	callerRequest.Header.Set(timeoutHeader, strconv.FormatInt(remains, 10))
+	return callerRequest
+}
+
+// 调用服务，设置了超时时间时在超时或客户端断开后立即返回，服务仍在运行但结果会被丢弃
+// 直接输出结果（Response、ResponseWriter）或读取请求内容（io.Reader）的服务在当前的 goroutine 中运行，只能通过 context 得知超时并自行结束
+func callService(service *webServiceType, parms []reflect.Value, ctx context.Context) ([]reflect.Value, error) {
+	running, ok := ctx.Value(runningServiceKey{}).(*runningService)
+	if !ok || service.responseIndex >= 0 || service.responseWriterIndex >= 0 || service.readerIndex >= 0 {
+		outs := service.funcValue.Call(parms)
+		if _, ok := ctx.Deadline(); ok && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return outs, nil
+	}
+
+	result := make(chan interface{}, 1)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer func() {
+			if panicErr := recover(); panicErr != nil {
+				result <- panicErr
+			}
+		}()
+		result <- service.funcValue.Call(parms)
+	}()
+
+	select {
+	case out := <-result:
+		<-done
+		if outs, ok := out.([]reflect.Value); ok {
+			return outs, nil
+		}
+		// 服务中的 panic 交给外层处理
+		panic(out)
+	case <-ctx.Done():
+		// 服务返回后释放资源，服务的结果不再处理
+		running.done = done
+		go func() {
+			<-done
+			if out := <-result; out != nil {
+				if _, ok := out.([]reflect.Value); !ok {
+					logError(fmt.Sprint(out), "timeout", true)
+				}
+			}
+		}()
+		return nil, ctx.Err()
+	}
+}
+
+// 超时返回 504，客户端断开返回 503
+func makeContextHTTPError(ctxErr, err error) *HTTPError {
+	if ctxErr == context.DeadlineExceeded {
+		return withCause(NewHTTPError(http.StatusGatewayTimeout, ""), err)
+	}
+	return withCause(NewHTTPError(http.StatusServiceUnavailable, ""), err)
+}
+
+// 是否为超时的错误，用于记录 TIMEOUT 日志
+func isTimeoutError(err error) bool {
+	for current := err; current != nil; {
+		if current == context.DeadlineExceeded {
+			return true
+		}
+		wrapper, ok := current.(interface{ Unwrap() error })
+		if !ok {
+			break
+		}
+		current = wrapper.Unwrap()
+	}
+	return false
+}
@@ -0,0 +1,126 @@
+package s
+
+import (
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// 上传的文件，在参数结构体中使用 UploadFile、*UploadFile、[]UploadFile、[]*UploadFile 类型的字段接收
+type UploadFile struct {
+	Name        string
+	Size        int64
+	ContentType string
+	header      *multipart.FileHeader
+}
+
+var uploadFileType = reflect.TypeOf(UploadFile{})
+
+type uploadField struct {
+	index int
+	name  string
+}
+
+// 打开上传的文件，超过内存限制的文件从临时文件中读取，请求结束后临时文件会被删除
+func (file *UploadFile) Open() (multipart.File, error) {
+	if file.header == nil {
+		return nil, os.ErrNotExist
+	}
+	return file.header.Open()
+}
+
+// 读取上传文件的全部内容
+func (file *UploadFile) Content() ([]byte, error) {
+	fp, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+	return ioutil.ReadAll(fp)
+}
+
+// 保存上传的文件
+func (file *UploadFile) Save(path string) error {
+	fp, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer fp.Close()
+	dst, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, fp)
+	closeErr := dst.Close()
+	if err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func makeUploadFile(header *multipart.FileHeader) UploadFile {
+	return UploadFile{Name: header.Filename, Size: header.Size, ContentType: header.Header.Get("Content-Type"), header: header}
+}
+
+// 参数结构体中接收上传文件的字段
+func getUploadFields(t reflect.Type) []uploadField {
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil
+	}
+	var fields []uploadField
+	for i := 0; i < t.NumField(); i++ {
+		ft := t.Field(i).Type
+		if ft.Kind() == reflect.Slice {
+			ft = ft.Elem()
+		}
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if ft == uploadFileType {
+			fields = append(fields, uploadField{index: i, name: t.Field(i).Name})
+		}
+	}
+	return fields
+}
+
+// 将上传的文件设置到参数结构体中，字段名不区分大小写
+func bindUploadFiles(fields []uploadField, form *multipart.Form, in reflect.Value) {
+	if form == nil {
+		return
+	}
+	for _, field := range fields {
+		var headers []*multipart.FileHeader
+		for k, v := range form.File {
+			if strings.EqualFold(k, field.name) {
+				headers = v
+				break
+			}
+		}
+		if len(headers) == 0 {
+			continue
+		}
+
+		fieldValue := in.Field(field.index)
+		fieldType := fieldValue.Type()
+		if fieldType.Kind() == reflect.Slice {
+			items := reflect.MakeSlice(fieldType, 0, len(headers))
+			for _, header := range headers {
+				items = reflect.Append(items, makeUploadFileValue(fieldType.Elem(), header))
+			}
+			fieldValue.Set(items)
+		} else {
+			fieldValue.Set(makeUploadFileValue(fieldType, headers[0]))
+		}
+	}
+}
+
+func makeUploadFileValue(t reflect.Type, header *multipart.FileHeader) reflect.Value {
+	file := makeUploadFile(header)
+	if t.Kind() == reflect.Ptr {
+		return reflect.ValueOf(&file)
+	}
+	return reflect.ValueOf(file)
+}
@@ -0,0 +1,427 @@
+package s
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// 参数校验失败的字段
+type FieldError struct {
+	Field   string
+	Rule    string
+	Message string
+}
+
+// 参数校验失败时返回的结果，状态码为 400
+type ValidationError struct {
+	Status  int
+	Message string
+	Fields  []FieldError
+}
+
+func (err *ValidationError) Error() string {
+	messages := make([]string, len(err.Fields))
+	for i, field := range err.Fields {
+		messages[i] = field.Field + " " + field.Message
+	}
+	return err.Message + ": " + strings.Join(messages, "; ")
+}
+
+type fieldValidator struct {
+	index     int
+	name      string
+	anonymous bool
+	required  bool
+	regex     *regexp.Regexp
+	min       *float64
+	max       *float64
+	minLength int
+	maxLength int
+	enum      []string
+	email     bool
+	nested    *typeValidator
+}
+
+type typeValidator struct {
+	fields []fieldValidator
+}
+
+var validators sync.Map
+var emailMatcher = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+
+// 按结构体的 tag 校验数据，返回校验失败的字段
+// 支持的 tag：required:"true"、regex:"^\\d+$"、min:"1"、max:"100"、length:"2,20"、enum:"a,b,c"、email:"true"
+func Validate(data interface{}) []FieldError {
+	return validateValue(reflect.ValueOf(data))
+}
+
+func validateValue(v reflect.Value) []FieldError {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+	validator := getValidator(v.Type())
+	if validator == nil {
+		return nil
+	}
+	errors := make([]FieldError, 0)
+	validator.validate(v, "", &errors)
+	return errors
+}
+
+func makeValidationError(errors []FieldError) *ValidationError {
+	return &ValidationError{Status: 400, Message: "invalid arguments", Fields: errors}
+}
+
+// 获取结构体的校验规则，没有任何规则时返回 nil
+func getValidator(t reflect.Type) *typeValidator {
+	return getValidatorWithParents(t, map[reflect.Type]bool{})
+}
+
+func getValidatorWithParents(t reflect.Type, parents map[reflect.Type]bool) *typeValidator {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || parents[t] {
+		return nil
+	}
+	// 字段名与输出的命名方式有关
+	key := namedFieldsKey{t, Config.FieldNaming}
+	if cached, ok := validators.Load(key); ok {
+		return cached.(*typeValidator)
+	}
+
+	parents[t] = true
+	defer delete(parents, t)
+
+	validator := &typeValidator{fields: make([]fieldValidator, 0)}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+		fv, err := makeFieldValidator(i, field)
+		if err != nil {
+			logError(err.Error(), "type", t.String(), "field", field.Name)
+		}
+		fv.nested = getValidatorWithParents(elemType(field.Type), parents)
+		if fv.required || fv.regex != nil || fv.min != nil || fv.max != nil || fv.minLength >= 0 || fv.maxLength >= 0 || fv.enum != nil || fv.email || fv.nested != nil {
+			validator.fields = append(validator.fields, *fv)
+		}
+	}
+	if len(validator.fields) == 0 {
+		validator = nil
+	}
+	validators.Store(key, validator)
+	return validator
+}
+
+func makeFieldValidator(index int, field reflect.StructField) (*fieldValidator, error) {
+	fv := &fieldValidator{index: index, name: fieldErrorName(field), anonymous: field.Anonymous, minLength: -1, maxLength: -1}
+	fv.required = field.Tag.Get("required") == "true"
+	fv.email = field.Tag.Get("email") == "true"
+	regex := field.Tag.Get("regex")
+	if regex == "" {
+		regex = getCheckTag(field.Tag)
+	}
+	if regex != "" {
+		matcher, err := regexp.Compile(regex)
+		if err != nil {
+			return fv, err
+		}
+		fv.regex = matcher
+	}
+	if min := field.Tag.Get("min"); min != "" {
+		n, err := strconv.ParseFloat(min, 64)
+		if err != nil {
+			return fv, err
+		}
+		fv.min = &n
+	}
+	if max := field.Tag.Get("max"); max != "" {
+		n, err := strconv.ParseFloat(max, 64)
+		if err != nil {
+			return fv, err
+		}
+		fv.max = &n
+	}
+	if length := field.Tag.Get("length"); length != "" {
+		// length:"5" 为固定长度，length:"2,20"、length:"2,"、length:",20" 为长度范围
+		var err error
+		minText, maxText := length, length
+		if pos := strings.IndexByte(length, ','); pos >= 0 {
+			minText, maxText = strings.TrimSpace(length[0:pos]), strings.TrimSpace(length[pos+1:])
+		}
+		if minText != "" {
+			if fv.minLength, err = strconv.Atoi(minText); err != nil {
+				return fv, err
+			}
+		}
+		if maxText != "" {
+			if fv.maxLength, err = strconv.Atoi(maxText); err != nil {
+				return fv, err
+			}
+		}
+	}
+	if enum := field.Tag.Get("enum"); enum != "" {
+		fv.enum = strings.Split(enum, ",")
+		for i := range fv.enum {
+			fv.enum[i] = strings.TrimSpace(fv.enum[i])
+		}
+	}
+	return fv, nil
+}
+
+// 数组、Map的元素类型，用于校验嵌套的结构体
+func elemType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map {
+		t = t.Elem()
+	}
+	return t
+}
+
+// check:"^\d+$" 与 regex 相同，兼容之前的 `check ^\d+$` 格式（tag 以 check 开头并且没有其他的 key:"value"，check 之后的内容都作为正则）
+func getCheckTag(tag reflect.StructTag) string {
+	if check := tag.Get("check"); check != "" {
+		return check
+	}
+	text := string(tag)
+	if !strings.HasPrefix(text, "check ") || strings.Contains(text, `:"`) {
+		return ""
+	}
+	return strings.TrimSpace(text[6:])
+}
+
+func (validator *typeValidator) validate(v reflect.Value, prefix string, errors *[]FieldError) {
+	for _, fv := range validator.fields {
+		field := v.Field(fv.index)
+		if fv.anonymous {
+			// 匿名嵌入的结构体字段与外层字段同级
+			for field.Kind() == reflect.Ptr && !field.IsNil() {
+				field = field.Elem()
+			}
+			if field.Kind() == reflect.Struct && fv.nested != nil {
+				fv.nested.validate(field, prefix, errors)
+				continue
+			}
+		}
+		fv.validate(field, prefix+fv.name, errors)
+	}
+}
+
+func (fv *fieldValidator) validate(v reflect.Value, name string, errors *[]FieldError) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			if fv.required {
+				*errors = append(*errors, FieldError{Field: name, Rule: "required", Message: "is required"})
+			}
+			return
+		}
+		v = v.Elem()
+	}
+
+	if isEmptyValue(v) {
+		if fv.required {
+			*errors = append(*errors, FieldError{Field: name, Rule: "required", Message: "is required"})
+			return
+		}
+		// 未填写的可选字段不做其他校验，数值和布尔值的零值是有效的值
+		if v.Kind() == reflect.String || v.Kind() == reflect.Slice || v.Kind() == reflect.Map {
+			return
+		}
+	}
+
+	if fv.minLength >= 0 || fv.maxLength >= 0 {
+		length := -1
+		switch v.Kind() {
+		case reflect.String:
+			length = utf8.RuneCountInString(v.String())
+		case reflect.Slice, reflect.Array, reflect.Map:
+			length = v.Len()
+		}
+		if length >= 0 && ((fv.minLength >= 0 && length < fv.minLength) || (fv.maxLength >= 0 && length > fv.maxLength)) {
+			*errors = append(*errors, FieldError{Field: name, Rule: "length", Message: "length must be " + fv.lengthText()})
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			fv.validateItem(v.Index(i), fmt.Sprint(name, "[", i, "]"), errors)
+		}
+	case reflect.Map:
+		if fv.nested != nil {
+			for _, key := range v.MapKeys() {
+				fv.validateItem(v.MapIndex(key), fmt.Sprint(name, ".", key.Interface()), errors)
+			}
+		}
+	default:
+		fv.validateItem(v, name, errors)
+	}
+}
+
+// 校验单个值，数组的每个元素分别校验
+func (fv *fieldValidator) validateItem(v reflect.Value, name string, errors *[]FieldError) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+
+	if v.Kind() == reflect.Struct && v.Type() != uploadFileType {
+		if fv.nested != nil {
+			fv.nested.validate(v, name+".", errors)
+		}
+		return
+	}
+
+	if fv.min != nil || fv.max != nil {
+		if n, ok := numberValue(v); ok {
+			if fv.min != nil && n < *fv.min {
+				*errors = append(*errors, FieldError{Field: name, Rule: "min", Message: "must be >= " + formatNumber(*fv.min)})
+			}
+			if fv.max != nil && n > *fv.max {
+				*errors = append(*errors, FieldError{Field: name, Rule: "max", Message: "must be <= " + formatNumber(*fv.max)})
+			}
+		}
+	}
+
+	if fv.regex == nil && fv.enum == nil && !fv.email {
+		return
+	}
+	var text string
+	if v.Kind() == reflect.String {
+		text = v.String()
+	} else if v.Kind() == reflect.Struct {
+		// 上传文件使用文件名校验 regex、enum
+		text = v.FieldByName("Name").String()
+	} else if v.Kind() == reflect.Slice || v.Kind() == reflect.Map {
+		return
+	} else {
+		text = fmt.Sprint(v.Interface())
+	}
+	if fv.regex != nil && !fv.regex.MatchString(text) {
+		*errors = append(*errors, FieldError{Field: name, Rule: "regex", Message: "must match " + fv.regex.String()})
+	}
+	if fv.email && !emailMatcher.MatchString(text) {
+		*errors = append(*errors, FieldError{Field: name, Rule: "email", Message: "must be an email"})
+	}
+	if fv.enum != nil {
+		found := false
+		for _, item := range fv.enum {
+			if item == text {
+				found = true
+				break
+			}
+		}
+		if !found {
+			*errors = append(*errors, FieldError{Field: name, Rule: "enum", Message: "must be one of " + strings.Join(fv.enum, ",")})
+		}
+	}
+}
+
+func (fv *fieldValidator) lengthText() string {
+	if fv.minLength == fv.maxLength {
+		return strconv.Itoa(fv.minLength)
+	}
+	if fv.maxLength < 0 {
+		return ">= " + strconv.Itoa(fv.minLength)
+	}
+	if fv.minLength < 0 {
+		return "<= " + strconv.Itoa(fv.maxLength)
+	}
+	return "between " + strconv.Itoa(fv.minLength) + " and " + strconv.Itoa(fv.maxLength)
+}
+
+// 字段的校验规则的文字说明，用于生成文档
+func (validator *typeValidator) rulesText(index int) string {
+	if validator == nil {
+		return ""
+	}
+	for i := range validator.fields {
+		if validator.fields[i].index == index {
+			return validator.fields[i].rulesText()
+		}
+	}
+	return ""
+}
+
+func (fv *fieldValidator) rulesText() string {
+	rules := make([]string, 0)
+	if fv.required {
+		rules = append(rules, "required")
+	}
+	if fv.regex != nil {
+		rules = append(rules, "regex:"+fv.regex.String())
+	}
+	if fv.min != nil {
+		rules = append(rules, "min:"+formatNumber(*fv.min))
+	}
+	if fv.max != nil {
+		rules = append(rules, "max:"+formatNumber(*fv.max))
+	}
+	if fv.minLength >= 0 || fv.maxLength >= 0 {
+		rules = append(rules, "length:"+fv.lengthText())
+	}
+	if fv.enum != nil {
+		rules = append(rules, "enum:"+strings.Join(fv.enum, ","))
+	}
+	if fv.email {
+		rules = append(rules, "email")
+	}
+	return strings.Join(rules, " ")
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	case reflect.Struct:
+		if v.Type() == uploadFileType {
+			return v.FieldByName("Name").String() == "" && v.FieldByName("Size").Int() == 0
+		}
+	}
+	return false
+}
+
+func numberValue(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	case reflect.Struct:
+		// 上传文件使用文件的尺寸校验 min、max
+		if v.Type() == uploadFileType {
+			return float64(v.FieldByName("Size").Int()), true
+		}
+	}
+	return 0, false
+}
+
+func formatNumber(n float64) string {
+	return strconv.FormatFloat(n, 'f', -1, 64)
+}
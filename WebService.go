@@ -8,7 +8,6 @@ import (
 	"github.com/ssgo/u"
 	"net/http"
 	"reflect"
-	"regexp"
 	"strings"
 )
 
@@ -17,11 +16,19 @@ type webServiceType struct {
 	priority            int
 	method              string
 	path                string
-	pathMatcher         *regexp.Regexp
-	pathArgs            []string
+	host                string
+	group               *ServiceGroup
+	middlewares         []func(*Context, func())
+	cors                *corsPolicy
+	formats             []string
+	maxBodySize         int
+	timeout             int
+	heartbeat           int
 	parmsNum            int
 	inType              reflect.Type
 	inIndex             int
+	uploadFields        []uploadField
+	bindings            []fieldBinding
 	headersType         reflect.Type
 	headersIndex        int
 	requestIndex        int
@@ -29,12 +36,20 @@ type webServiceType struct {
 	responseWriterIndex int
 	loggerIndex         int
 	callerIndex         int
+	serviceCallerIndex  int
+	readerIndex         int
+	contextIndex        int
+	sseIndex            int
+	errorIndex          int
 	funcType            reflect.Type
 	funcValue           reflect.Value
 }
 
+type ServiceRegister struct {
+	service *webServiceType
+}
+
 var webServices = make(map[string]*webServiceType)
-var regexWebServices = make([]*webServiceType, 0)
 
 var inFilters = make([]func(*map[string]interface{}, *http.Request, *http.ResponseWriter) interface{}, 0)
 var outFilters = make([]func(*map[string]interface{}, *http.Request, *http.ResponseWriter, interface{}) (interface{}, bool), 0)
@@ -43,8 +58,6 @@ var webAuthChecker func(int, *string, *map[string]interface{}, *http.Request) bo
 var sessionKey string
 var clientKey string
 var sessionCreator func() string
-var sessionObjects = map[*http.Request]map[reflect.Type]interface{}{}
-var injectObjects = map[reflect.Type]interface{}{}
 
 // 设置 SessionKey，自动在 Header 中产生，AsyncStart 的客户端支持自动传递
 func SetSessionKey(inSessionKey string) {
@@ -74,84 +87,83 @@ func GetSessionId(request *http.Request) string {
 	return request.Header.Get(sessionKey)
 }
 
-// 设置一个生命周期在 Request 中的对象，请求中可以使用对象类型注入参数方便调用
-func SetSessionInject(request *http.Request, obj interface{}) {
-	if sessionObjects[request] == nil {
-		sessionObjects[request] = map[reflect.Type]interface{}{}
-	}
-	sessionObjects[request][reflect.TypeOf(obj)] = obj
-}
-
-// 获取本生命周期中指定类型的 Session 对象
-func GetSessionInject(request *http.Request, dataType reflect.Type) interface{} {
-	if sessionObjects[request] == nil {
-		return nil
-	}
-	return sessionObjects[request][dataType]
+// 注册服务
+func Register(authLevel int, path string, serviceFunc interface{}) *ServiceRegister {
+	return Restful(authLevel, "", path, serviceFunc)
 }
 
-// 设置一个注入对象，请求中可以使用对象类型注入参数方便调用
-func SetInject(obj interface{}) {
-	injectObjects[reflect.TypeOf(obj)] = obj
+// 注册服务
+func Restful(authLevel int, method, path string, serviceFunc interface{}) *ServiceRegister {
+	return RestfulWithPriority(authLevel, 0, method, path, serviceFunc)
 }
 
-// 获取一个注入对象
-func GetInject(dataType reflect.Type) interface{} {
-	return injectObjects[dataType]
+// 注册服务
+func RegisterWithPriority(authLevel, priority int, path string, serviceFunc interface{}) *ServiceRegister {
+	return RestfulWithPriority(authLevel, priority, "", path, serviceFunc)
 }
 
 // 注册服务
-func Register(authLevel int, path string, serviceFunc interface{}) {
-	Restful(authLevel, "", path, serviceFunc)
+func RestfulWithPriority(authLevel, priority int, method, path string, serviceFunc interface{}) *ServiceRegister {
+	return restful(nil, "", authLevel, priority, method, path, serviceFunc)
 }
 
-// 注册服务
-func Restful(authLevel int, method, path string, serviceFunc interface{}) {
-	RestfulWithPriority(authLevel, 0, method, path, serviceFunc)
+// 注册只响应指定域名的服务，host 支持通配，例如 *.abc.com、{tenant}.abc.com，{tenant} 会作为参数传入服务
+func RegisterWithHost(host string, authLevel int, path string, serviceFunc interface{}) *ServiceRegister {
+	return RestfulWithHost(host, authLevel, "", path, serviceFunc)
 }
 
-// 注册服务
-func RegisterWithPriority(authLevel, priority int, path string, serviceFunc interface{}) {
-	RestfulWithPriority(authLevel, priority, "", path, serviceFunc)
+// 注册只响应指定域名的服务
+func RestfulWithHost(host string, authLevel int, method, path string, serviceFunc interface{}) *ServiceRegister {
+	return restful(nil, host, authLevel, 0, method, path, serviceFunc)
 }
 
-// 注册服务
-func RestfulWithPriority(authLevel, priority int, method, path string, serviceFunc interface{}) {
+func restful(group *ServiceGroup, host string, authLevel, priority int, method, path string, serviceFunc interface{}) *ServiceRegister {
+	method = strings.ToUpper(method)
 	s, err := makeCachedService(serviceFunc)
 	if err != nil {
 		logError(err.Error(), "authLevel", authLevel, "priority", priority, "path", path, "method", method)
-		return
+		return &ServiceRegister{}
 	}
 
 	s.authLevel = authLevel
 	s.priority = priority
 	s.method = method
 	s.path = path
-	finder, err := regexp.Compile("{(.*?)}")
-	if err == nil {
-		keyName := regexp.QuoteMeta(path)
-		finds := finder.FindAllStringSubmatch(path, 20)
-		for _, found := range finds {
-			keyName = strings.Replace(keyName, regexp.QuoteMeta(found[0]), "(.*?)", 1)
-			s.pathArgs = append(s.pathArgs, found[1])
-		}
-		if len(s.pathArgs) > 0 {
-			s.pathMatcher, err = regexp.Compile("^" + keyName + "$")
-			if err != nil {
-				logError(err.Error(), Map{
-					"authLevel": authLevel,
-					"priority":  priority,
-					"path":      path,
-					"method":    method,
-				})
-				//log.Print("Register	Compile	", err)
-			}
-			regexWebServices = append(regexWebServices, s)
-		}
+	s.host = strings.ToLower(host)
+	s.group = group
+	err = checkRoutePath(s.host, path)
+	if err != nil {
+		logError(err.Error(), "authLevel", authLevel, "priority", priority, "path", path, "method", method, "host", host)
+		return &ServiceRegister{}
 	}
-	if s.pathMatcher == nil {
-		webServices[method+path] = s
+	updateRoutes(func() {
+		// 同一个函数重复注册同一个路径不作为问题
+		if old := webServices[s.host+method+path]; old != nil && old.funcValue.Pointer() != s.funcValue.Pointer() {
+			addRouteDuplicate(u.StringIf(method != "", method, "*") + " " + s.host + path)
+		}
+		webServices[s.host+method+path] = s
+	})
+	return &ServiceRegister{service: s}
+}
+
+// 设置服务的请求内容的最大尺寸，超过时返回 413，服务接收 io.Reader 时读取超过的部分会返回错误
+func (sr *ServiceRegister) SetMaxBodySize(size int) *ServiceRegister {
+	if sr.service != nil {
+		sr.service.maxBodySize = size
 	}
+	return sr
+}
+
+// 删除服务，可以在服务运行中调用，正在处理的请求不受影响
+func Unregister(method, path string) {
+	UnregisterWithHost("", method, path)
+}
+
+// 删除指定域名的服务
+func UnregisterWithHost(host, method, path string) {
+	updateRoutes(func() {
+		delete(webServices, strings.ToLower(host)+strings.ToUpper(method)+path)
+	})
 }
 
 // 设置前置过滤器
@@ -265,11 +277,12 @@ func SetErrorHandle(myErrorHandle func(err interface{}, request *http.Request, r
 //}
 
 func doWebService(service *webServiceType, request *http.Request, response *http.ResponseWriter, args *map[string]interface{},
-	result interface{}, requestLogger *log.Logger) (webResult interface{}) {
+	sources *argSources, result interface{}, requestLogger *log.Logger) (webResult interface{}) {
 	// 反射调用
 	if result != nil {
 		return result
 	}
+	serviceCtx := request.Context()
 	// 生成参数
 	var parms = make([]reflect.Value, service.parmsNum)
 	if service.inIndex >= 0 {
@@ -277,7 +290,18 @@ func doWebService(service *webServiceType, request *http.Request, response *http
 			parms[service.inIndex] = reflect.ValueOf(args).Elem()
 		} else {
 			in := reflect.New(service.inType).Interface()
-			u.Convert(args, in)
+			var convertErrors []FieldError
+			if service.bindings != nil && sources != nil {
+				convertErrors = convertInput(makeBindingArgs(service.bindings, *args, sources, request), in)
+			} else {
+				convertErrors = convertInput(*args, in)
+			}
+			if service.uploadFields != nil {
+				bindUploadFiles(service.uploadFields, request.MultipartForm, reflect.ValueOf(in).Elem())
+			}
+			if errors := mergeFieldErrors(convertErrors, validateValue(reflect.ValueOf(in))); len(errors) > 0 {
+				return &Result{Status: http.StatusBadRequest, Body: makeValidationError(errors)}
+			}
 			parms[service.inIndex] = reflect.ValueOf(in).Elem()
 		}
 	}
@@ -296,6 +320,9 @@ func doWebService(service *webServiceType, request *http.Request, response *http
 		} else {
 			headers := reflect.New(service.headersType).Interface()
 			u.Convert(headersMap, headers)
+			if errors := validateValue(reflect.ValueOf(headers)); len(errors) > 0 {
+				return &Result{Status: http.StatusBadRequest, Body: makeValidationError(errors)}
+			}
 			parms[service.headersIndex] = reflect.ValueOf(headers).Elem()
 		}
 	}
@@ -313,39 +340,59 @@ func doWebService(service *webServiceType, request *http.Request, response *http
 	if service.loggerIndex >= 0 {
 		parms[service.loggerIndex] = reflect.ValueOf(requestLogger)
 	}
+	if service.readerIndex >= 0 {
+		if request.Body != nil {
+			parms[service.readerIndex] = reflect.ValueOf(request.Body)
+		} else {
+			parms[service.readerIndex] = reflect.ValueOf(http.NoBody)
+		}
+	}
+	if service.contextIndex >= 0 {
+		parms[service.contextIndex] = reflect.ValueOf(serviceCtx)
+	}
 	if service.callerIndex >= 0 {
-		caller := &discover.Caller{Request: request}
+		caller := &discover.Caller{Request: makeCallerRequest(request, serviceCtx)}
 		parms[service.callerIndex] = reflect.ValueOf(caller)
 	}
+	if service.serviceCallerIndex >= 0 {
+		caller := &Caller{Request: request, ctx: serviceCtx, logger: requestLogger}
+		parms[service.serviceCallerIndex] = reflect.ValueOf(caller)
+	}
 	for i, parm := range parms {
 		if parm.Kind() == reflect.Invalid {
-			st := service.funcType.In(i)
-			isset := false
-			if st.Kind() == reflect.Struct || (st.Kind() == reflect.Ptr && st.Elem().Kind() == reflect.Struct) {
-				sessObj := GetSessionInject(request, st)
-				if sessObj != nil {
-					parms[i] = reflect.ValueOf(sessObj)
-					isset = true
-				} else {
-					injectObj := GetInject(st)
-					if injectObj != nil {
-						injectObjValue := reflect.ValueOf(injectObj)
-						setLoggerMethod, found := injectObjValue.Type().MethodByName("SetLogger")
-						if found && setLoggerMethod.Type.NumIn() == 2 && setLoggerMethod.Type.In(1).String() == "*log.Logger" {
-							setLoggerMethod.Func.Call([]reflect.Value{injectObjValue, reflect.ValueOf(requestLogger)})
-						}
-						parms[i] = injectObjValue
-						isset = true
-					}
-				}
-			}
-			if isset == false {
-				parms[i] = reflect.New(st).Elem()
+			v, err := resolveInject(request, service.funcType.In(i), requestLogger, false)
+			if err != nil {
+				httpErr := makeHTTPError(err)
+				return &Result{Status: httpErr.Status, Body: httpErr}
 			}
+			parms[i] = v
+		}
+	}
+	// SSE 服务在参数准备好之后开始输出，服务返回后结束
+	if service.sseIndex >= 0 {
+		sender := newSSESender(service, request, *response)
+		defer sender.close()
+		parms[service.sseIndex] = reflect.ValueOf(sender)
+		if service.contextIndex >= 0 {
+			parms[service.contextIndex] = reflect.ValueOf(sender.ctx)
 		}
+		outs := service.funcValue.Call(parms)
+		if service.errorIndex >= 0 && !outs[service.errorIndex].IsNil() {
+			sender.err = outs[service.errorIndex].Interface().(error)
+		}
+		return sender
+	}
+	outs, err := callService(service, parms, serviceCtx)
+	if err != nil {
+		httpErr := makeContextHTTPError(err, err)
+		return &Result{Status: httpErr.Status, Body: httpErr}
 	}
-	outs := service.funcValue.Call(parms)
-	if len(outs) > 0 {
+	// 返回的 error 不为 nil 时按错误的状态码输出
+	if service.errorIndex >= 0 && !outs[service.errorIndex].IsNil() {
+		httpErr := makeHTTPError(outs[service.errorIndex].Interface().(error))
+		return &Result{Status: httpErr.Status, Body: httpErr}
+	}
+	if len(outs) > 0 && service.errorIndex != 0 {
 		webResult = outs[0].Interface()
 	} else {
 		webResult = ""
@@ -383,6 +430,10 @@ func makeCachedService(matchedServie interface{}) (*webServiceType, error) {
 	targetService.responseWriterIndex = -1
 	targetService.loggerIndex = -1
 	targetService.callerIndex = -1
+	targetService.serviceCallerIndex = -1
+	targetService.readerIndex = -1
+	targetService.contextIndex = -1
+	targetService.sseIndex = -1
 	for i := 0; i < targetService.parmsNum; i++ {
 		t := funcType.In(i)
 		if t.String() == "*http.Request" {
@@ -397,6 +448,14 @@ func makeCachedService(matchedServie interface{}) (*webServiceType, error) {
 			//	targetService.headersIndex = i
 		} else if t.String() == "*discover.Caller" {
 			targetService.callerIndex = i
+		} else if t.String() == "*s.Caller" {
+			targetService.serviceCallerIndex = i
+		} else if t.String() == "io.Reader" {
+			targetService.readerIndex = i
+		} else if t == contextType {
+			targetService.contextIndex = i
+		} else if t.String() == "*s.SSESender" {
+			targetService.sseIndex = i
 		} else if t.Kind() == reflect.Struct || (t.Kind() == reflect.Map && t.Elem().Kind() == reflect.Interface) || (t.Kind() == reflect.Map && t.Elem().Kind() == reflect.String) {
 			if targetService.inType == nil {
 				targetService.inIndex = i
@@ -408,13 +467,19 @@ func makeCachedService(matchedServie interface{}) (*webServiceType, error) {
 		}
 	}
 
+	targetService.errorIndex = -1
+	if funcType.NumOut() > 0 && funcType.Out(funcType.NumOut()-1) == errorType {
+		targetService.errorIndex = funcType.NumOut() - 1
+	}
+	targetService.uploadFields = getUploadFields(targetService.inType)
+	targetService.bindings = getFieldBindings(targetService.inType)
 	targetService.funcType = funcType
 	targetService.funcValue = reflect.ValueOf(matchedServie)
 	return targetService, nil
 }
 
 func makeBytesResult(data interface{}) []byte {
-	bytesResult, err := json.Marshal(data)
+	bytesResult, err := json.Marshal(makeNamedData(data))
 	if err != nil || (len(bytesResult) == 4 && string(bytesResult) == "null") {
 		t := reflect.TypeOf(data)
 		if t.Kind() == reflect.Slice {
@@ -424,6 +489,5 @@ func makeBytesResult(data interface{}) []byte {
 			bytesResult = []byte("{}")
 		}
 	}
-	u.FixUpperCase(bytesResult, nil)
 	return bytesResult
 }
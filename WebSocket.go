@@ -5,8 +5,7 @@ import (
 	"github.com/ssgo/log"
 	"net/http"
 	"reflect"
-	"regexp"
-	"strings"
+	"sort"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -17,8 +16,8 @@ type websocketServiceType struct {
 	authLevel         int
 	priority          int
 	path              string
-	pathMatcher       *regexp.Regexp
-	pathArgs          []string
+	group             *ServiceGroup
+	middlewares       []func(*Context, func())
 	updater           *websocket.Upgrader
 	openParmsNum      int
 	openInType        reflect.Type
@@ -62,7 +61,6 @@ type ActionRegister struct {
 }
 
 var websocketServices = make(map[string]*websocketServiceType)
-var regexWebsocketServices = make([]*websocketServiceType, 0)
 
 var webSocketActionAuthChecker func(int, *string, *string, *map[string]interface{}, *http.Request, interface{}) bool
 
@@ -81,11 +79,20 @@ func RegisterWebsocketWithPriority(authLevel, priority int, path string, updater
 	onClose interface{},
 	decoder func(data interface{}) (action string, request *map[string]interface{}, err error),
 	encoder func(action string, data interface{}) interface{}) *ActionRegister {
+	return registerWebsocket(nil, authLevel, priority, path, updater, onOpen, onClose, decoder, encoder)
+}
+
+func registerWebsocket(group *ServiceGroup, authLevel, priority int, path string, updater *websocket.Upgrader,
+	onOpen interface{},
+	onClose interface{},
+	decoder func(data interface{}) (action string, request *map[string]interface{}, err error),
+	encoder func(action string, data interface{}) interface{}) *ActionRegister {
 
 	s := new(websocketServiceType)
 	s.authLevel = authLevel
 	s.priority = priority
 	s.path = path
+	s.group = group
 	if updater == nil {
 		s.updater = new(websocket.Upgrader)
 	} else {
@@ -153,35 +160,28 @@ func RegisterWebsocketWithPriority(authLevel, priority int, path string, updater
 		}
 	}
 
-	finder, err := regexp.Compile("{(.*?)}")
-	if err == nil {
-		keyName := regexp.QuoteMeta(path)
-		finds := finder.FindAllStringSubmatch(path, 20)
-		for _, found := range finds {
-			keyName = strings.Replace(keyName, regexp.QuoteMeta(found[0]), "(.*?)", 1)
-			s.pathArgs = append(s.pathArgs, found[1])
-		}
-		if len(s.pathArgs) > 0 {
-			s.pathMatcher, _ = regexp.Compile("^" + keyName + "$")
-			if err != nil {
-				logError(err.Error(), Map{
-					"authLevel": authLevel,
-					"priority":  priority,
-					"path":      path,
-				})
-				//log.Print("RegisterWebsocket	Compile	", err)
+	err := checkRoutePath("", path)
+	if err != nil {
+		logError(err.Error(), "authLevel", authLevel, "priority", priority, "path", path)
+	} else {
+		updateRoutes(func() {
+			if websocketServices[path] != nil {
+				addRouteDuplicate("WS " + path)
 			}
-			//regexWebsocketServices[path] = s
-			regexWebsocketServices = append(regexWebsocketServices, s)
-		}
-	}
-	if s.pathMatcher == nil {
-		websocketServices[path] = s
+			websocketServices[path] = s
+		})
 	}
 
 	return &ActionRegister{websocketName: path, websocketServiceType: s}
 }
 
+// 删除Websocket服务，已经建立的连接不受影响
+func UnregisterWebsocket(path string) {
+	updateRoutes(func() {
+		delete(websocketServices, path)
+	})
+}
+
 func (ar *ActionRegister) RegisterAction(authLevel int, actionName string, action interface{}) {
 	ar.RegisterActionWithPriority(authLevel, 0, actionName, action)
 }
@@ -226,6 +226,21 @@ func doWebsocketService(ws *websocketServiceType, request *http.Request, respons
 	//byteArgs, _ := json.Marshal(*args)
 	//byteHeaders, _ := json.Marshal(*headers)
 
+	// 在升级为 Websocket 之前校验参数
+	var openIn interface{}
+	if ws.openFuncType != nil && ws.openInIndex >= 0 {
+		openIn = reflect.New(ws.openInType).Interface()
+		convertErrors := convertInput(*args, openIn)
+		if errors := mergeFieldErrors(convertErrors, validateValue(reflect.ValueOf(openIn))); len(errors) > 0 {
+			result := makeValidationError(errors)
+			outBytes := makeBytesResult(result)
+			response.WriteHeader(400)
+			_, _ = response.Write(outBytes)
+			writeLog(requestLogger, "FAIL", result, len(outBytes), request, response, args, headers, startTime, authLevel, nil)
+			return
+		}
+	}
+
 	message := "OK"
 	client, err := ws.updater.Upgrade(response.writer, request, nil)
 	if err != nil {
@@ -242,9 +257,7 @@ func doWebsocketService(ws *websocketServiceType, request *http.Request, respons
 		if ws.openFuncType != nil {
 			var openParms = make([]reflect.Value, ws.openParmsNum)
 			if ws.openInIndex >= 0 {
-				in := reflect.New(ws.openInType).Interface()
-				u.Convert(*args, in)
-				openParms[ws.openInIndex] = reflect.ValueOf(in).Elem()
+				openParms[ws.openInIndex] = reflect.ValueOf(openIn).Elem()
 			}
 			if ws.openHeadersIndex >= 0 {
 				//openParms[ws.openRequestIndex] = reflect.ValueOf(&request.Header)
@@ -264,23 +277,11 @@ func doWebsocketService(ws *websocketServiceType, request *http.Request, respons
 
 			for i, parm := range openParms {
 				if parm.Kind() == reflect.Invalid {
-					st := ws.openFuncType.In(i)
-					isset := false
-					if st.Kind() == reflect.Struct || (st.Kind() == reflect.Ptr && st.Elem().Kind() == reflect.Struct) {
-						injectObj := GetInject(st)
-						if injectObj != nil {
-							injectObjValue := reflect.ValueOf(injectObj)
-							setLoggerMethod, found := injectObjValue.Type().MethodByName("SetLogger")
-							if found && setLoggerMethod.Type.NumIn() == 2 && setLoggerMethod.Type.In(1).String() == "*log.Logger" {
-								setLoggerMethod.Func.Call([]reflect.Value{injectObjValue, reflect.ValueOf(requestLogger)})
-							}
-							openParms[i] = injectObjValue
-							isset = true
-						}
-					}
-					if isset == false {
-						openParms[i] = reflect.New(st).Elem()
+					v, err := resolveInject(request, ws.openFuncType.In(i), requestLogger, false)
+					if err != nil {
+						logError(err.Error(), "type", ws.openFuncType.In(i).String())
 					}
+					openParms[i] = v
 				}
 			}
 
@@ -399,23 +400,11 @@ func doWebsocketService(ws *websocketServiceType, request *http.Request, respons
 
 			for i, parm := range closeParms {
 				if parm.Kind() == reflect.Invalid {
-					st := ws.openFuncType.In(i)
-					isset := false
-					if st.Kind() == reflect.Struct || (st.Kind() == reflect.Ptr && st.Elem().Kind() == reflect.Struct) {
-						injectObj := GetInject(st)
-						if injectObj != nil {
-							injectObjValue := reflect.ValueOf(injectObj)
-							setLoggerMethod, found := injectObjValue.Type().MethodByName("SetLogger")
-							if found && setLoggerMethod.Type.NumIn() == 2 && setLoggerMethod.Type.In(1).String() == "*log.Logger" {
-								setLoggerMethod.Func.Call([]reflect.Value{injectObjValue, reflect.ValueOf(requestLogger)})
-							}
-							closeParms[i] = injectObjValue
-							isset = true
-						}
-					}
-					if isset == false {
-						closeParms[i] = reflect.New(st).Elem()
+					v, err := resolveInject(request, ws.closeFuncType.In(i), requestLogger, false)
+					if err != nil {
+						logError(err.Error(), "type", ws.closeFuncType.In(i).String())
 					}
+					closeParms[i] = v
 				}
 			}
 
@@ -430,7 +419,16 @@ func doWebsocketAction(ws *websocketServiceType, actionName string, action *webs
 	var messageParms = make([]reflect.Value, action.parmsNum)
 	if action.inType != nil {
 		in := reflect.New(action.inType).Interface()
-		u.Convert(*data, in)
+		convertErrors := convertInput(*data, in)
+		if errors := mergeFieldErrors(convertErrors, validateValue(reflect.ValueOf(in))); len(errors) > 0 {
+			// 参数校验失败时使用请求的 action 返回失败的字段
+			result := makeValidationError(errors)
+			outLen, err := sendWebsocketMessage(ws, client, actionName, *result)
+			if err == nil {
+				err = result
+			}
+			return actionName, result, outLen, err
+		}
 		messageParms[action.inIndex] = reflect.ValueOf(in).Elem()
 	}
 	if action.sessionIndex >= 0 {
@@ -444,29 +442,11 @@ func doWebsocketAction(ws *websocketServiceType, actionName string, action *webs
 	}
 	for i, parm := range messageParms {
 		if parm.Kind() == reflect.Invalid {
-			st := action.funcType.In(i)
-			isset := false
-			if st.Kind() == reflect.Struct || (st.Kind() == reflect.Ptr && st.Elem().Kind() == reflect.Struct) {
-				sessObj := GetSessionInject(request, st)
-				if sessObj != nil {
-					messageParms[i] = reflect.ValueOf(sessObj)
-					isset = true
-				} else {
-					injectObj := GetInject(st)
-					if injectObj != nil {
-						injectObjValue := reflect.ValueOf(injectObj)
-						setLoggerMethod, found := injectObjValue.Type().MethodByName("SetLogger")
-						if found && setLoggerMethod.Type.NumIn() == 2 && setLoggerMethod.Type.In(1).String() == "*log.Logger" {
-							setLoggerMethod.Func.Call([]reflect.Value{injectObjValue, reflect.ValueOf(requestLogger)})
-						}
-						messageParms[i] = injectObjValue
-						isset = true
-					}
-				}
-			}
-			if isset == false {
-				messageParms[i] = reflect.New(st).Elem()
+			v, err := resolveInject(request, action.funcType.In(i), requestLogger, true)
+			if err != nil {
+				logError(err.Error(), "type", action.funcType.In(i).String())
 			}
+			messageParms[i] = v
 		}
 	}
 
@@ -483,31 +463,8 @@ func doWebsocketAction(ws *websocketServiceType, actionName string, action *webs
 			outData = outs[0].Interface()
 		}
 
-		var outBytes []byte
 		var err error
-		if ws.encoder != nil {
-			outBytes, err = json.Marshal(ws.encoder(outAction, outData))
-		} else {
-			outDataType := reflect.TypeOf(outData)
-			var outDataMap map[string]interface{}
-			if outDataType.Kind() == reflect.Map && outDataType.Elem().Kind() == reflect.Interface {
-				outDataMap = outData.(map[string]interface{})
-			} else if outDataType.Kind() == reflect.Struct {
-				u.Convert(outData, &outDataMap)
-			} else {
-				outDataMap = map[string]interface{}{}
-				outDataMap["data"] = outData
-			}
-			outDataMap["action"] = outAction
-			outBytes, err = json.Marshal(outDataMap)
-		}
-		outLen = len(outBytes)
-
-		if err != nil {
-			return outAction, outData, outLen, err
-		}
-		u.FixUpperCase(outBytes, nil)
-		err = client.WriteMessage(websocket.TextMessage, outBytes)
+		outLen, err = sendWebsocketMessage(ws, client, outAction, outData)
 		if err != nil {
 			return outAction, outData, outLen, err
 		}
@@ -515,3 +472,41 @@ func doWebsocketAction(ws *websocketServiceType, actionName string, action *webs
 
 	return outAction, outData, outLen, nil
 }
+
+func sendWebsocketMessage(ws *websocketServiceType, client *websocket.Conn, outAction string, outData interface{}) (int, error) {
+	var outBytes []byte
+	var err error
+	if ws.encoder != nil {
+		outBytes, err = json.Marshal(makeNamedData(ws.encoder(outAction, outData)))
+	} else {
+		outDataType := reflect.TypeOf(outData)
+		outObject := &namedObject{}
+		if outDataType != nil && outDataType.Kind() == reflect.Map && outDataType.Elem().Kind() == reflect.Interface {
+			outMap, _ := makeNamedData(outData).(map[string]interface{})
+			keys := make([]string, 0, len(outMap))
+			for k := range outMap {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				outObject.set(k, outMap[k])
+			}
+		} else {
+			// 实现了 json.Marshaler 或 encoding.TextMarshaler 的结构体（例如 time.Time）和其他类型作为 data 输出
+			named := makeNamedData(outData)
+			if object, ok := named.(*namedObject); ok && outDataType.Kind() == reflect.Struct {
+				outObject = object
+			} else {
+				outObject.set("data", named)
+			}
+		}
+		outObject.set("action", outAction)
+		outBytes, err = json.Marshal(outObject)
+	}
+	outLen := len(outBytes)
+
+	if err != nil {
+		return outLen, err
+	}
+	return outLen, client.WriteMessage(websocket.TextMessage, outBytes)
+}
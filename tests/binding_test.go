@@ -0,0 +1,57 @@
+package tests
+
+import (
+	"os"
+	"testing"
+
+	"github.com/ssgo/s"
+)
+
+func TestBinding(tt *testing.T) {
+	t := s.T(tt)
+
+	_ = os.Setenv("LOG_FILE", os.DevNull)
+	s.ResetAllSets()
+
+	s.Restful(0, "POST", "/users/{id}", func(in struct {
+		Id       int
+		Name     string `s:"body"`
+		Page     int    `s:"query"`
+		Token    string `s:"header"`
+		ClientId string `s:"header:X-Client-Id"`
+		Session  string `s:"cookie"`
+		Type     string `s:"query:t"`
+	}) s.Map {
+		return s.Map{"id": in.Id, "name": in.Name, "page": in.Page, "token": in.Token, "clientId": in.ClientId, "session": in.Session, "type": in.Type}
+	})
+	s.Restful(0, "POST", "/path/{code}", func(in struct {
+		Code string `s:"path"`
+	}) string {
+		return in.Code
+	})
+
+	as := s.AsyncStart()
+	defer as.Stop()
+
+	d := as.Post("/users/12?page=3&name=fromQuery&t=admin", s.Map{"id": 99, "name": "Tom", "page": 8, "token": "fromBody"},
+		"Token", "abc", "X-Client-Id", "c1", "Cookie", "session=s1").Map()
+	t.Test(d["id"] == float64(12), "path not overridden by body", d)
+	t.Test(d["name"] == "Tom" && d["page"] == float64(3), "body and query sources", d)
+	t.Test(d["token"] == "abc" && d["clientId"] == "c1", "header source", d)
+	t.Test(d["session"] == "s1" && d["type"] == "admin", "cookie and named source", d)
+
+	d = as.Post("/users/12?name=fromQuery", s.Map{"token": "fromBody", "session": "fromBody"}).Map()
+	t.Test(d["name"] == "" && d["token"] == "" && d["session"] == "", "no cross source values", d)
+
+	r := as.Post("/path/abc?code=q", s.Map{"code": "b"}).String()
+	t.Test(r == "abc", "path source", r)
+
+	found := false
+	for _, api := range s.MakeDocument() {
+		if api.Path == "/users/{id}" {
+			in, _ := api.In.(s.Map)
+			found = in["Id"] == "int in:path" && in["Name"] == "string in:body" && in["ClientId"] == "string in:header(X-Client-Id)"
+		}
+	}
+	t.Test(found, "sources in document", s.MakeDocument())
+}
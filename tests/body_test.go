@@ -0,0 +1,107 @@
+package tests
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/ssgo/s"
+)
+
+func TestBodyLimit(tt *testing.T) {
+	t := s.T(tt)
+
+	_ = os.Setenv("LOG_FILE", os.DevNull)
+	_ = os.Setenv("service_httpVersion", "1")
+	_ = os.Setenv("SERVICE_MAXBODYSIZE", "100")
+	_ = os.Setenv("SERVICE_MAXJSONDEPTH", "3")
+	defer func() {
+		_ = os.Unsetenv("service_httpVersion")
+		_ = os.Unsetenv("SERVICE_MAXBODYSIZE")
+		_ = os.Unsetenv("SERVICE_MAXJSONDEPTH")
+	}()
+	s.ResetAllSets()
+
+	s.Restful(0, "POST", "/echo", Echo4)
+	s.Restful(0, "POST", "/bigEcho", Echo4).SetMaxBodySize(1000)
+	s.Restful(0, "POST", "/stream", func(in struct{ Name string }, body io.Reader) string {
+		data, err := ioutil.ReadAll(body)
+		if err != nil {
+			return "error"
+		}
+		return in.Name + ":" + string(data)
+	})
+	s.Restful(0, "POST", "/smallStream", func(body io.Reader) string {
+		_, err := ioutil.ReadAll(body)
+		if err != nil {
+			return "error"
+		}
+		return "ok"
+	}).SetMaxBodySize(10)
+
+	as := s.AsyncStart()
+	defer as.Stop()
+
+	big := `{"name":"` + strings.Repeat("x", 200) + `"}`
+	res, data := postRaw("http://"+as.Addr+"/echo", "application/json", []byte(big))
+	t.Test(res != nil && res.StatusCode == 413, "content length too large", res, string(data))
+
+	// 没有 Content-Length 时读取超过限制
+	res, err := http.Post("http://"+as.Addr+"/echo", "application/json", io.MultiReader(strings.NewReader(big)))
+	t.Test(err == nil && res.StatusCode == 413, "chunked body too large", res, err)
+
+	res, data = postRaw("http://"+as.Addr+"/echo", "application/x-www-form-urlencoded", []byte("name="+strings.Repeat("x", 200)))
+	t.Test(res != nil && res.StatusCode == 413, "form too large", res, string(data))
+
+	res, data = postRaw("http://"+as.Addr+"/echo", "application/json", []byte(`{"a":{"b":{"c":[1]}}}`))
+	t.Test(res != nil && res.StatusCode == 400, "json too deep", res, string(data))
+
+	res, data = postRaw("http://"+as.Addr+"/echo", "application/json", []byte(`{"a":{"b":["[[[{"]}}`))
+	t.Test(res != nil && res.StatusCode == 200, "json depth ignores strings", res, string(data))
+
+	res, data = postRaw("http://"+as.Addr+"/bigEcho", "application/json", []byte(big))
+	t.Test(res != nil && res.StatusCode == 200 && strings.Contains(string(data), strings.Repeat("x", 200)), "route max body size", res, string(data))
+
+	res, data = postRaw("http://"+as.Addr+"/stream?name=abc", "application/octet-stream", []byte(strings.Repeat("y", 50)))
+	t.Test(res != nil && res.StatusCode == 200 && string(data) == "abc:"+strings.Repeat("y", 50), "stream body", res, string(data))
+
+	// 没有单独设置时使用全局的限制
+	res, data = postRaw("http://"+as.Addr+"/stream?name=abc", "application/octet-stream", []byte(strings.Repeat("y", 500)))
+	t.Test(res != nil && res.StatusCode == 413, "stream body global limit", res, string(data))
+
+	res, err = http.Post("http://"+as.Addr+"/smallStream", "application/octet-stream", io.MultiReader(strings.NewReader(strings.Repeat("y", 50))))
+	if err == nil {
+		data, _ = ioutil.ReadAll(res.Body)
+		_ = res.Body.Close()
+	}
+	t.Test(err == nil && string(data) == "error", "stream body limit", res, err)
+}
+
+func TestUnlimitedBody(tt *testing.T) {
+	t := s.T(tt)
+
+	_ = os.Setenv("LOG_FILE", os.DevNull)
+	_ = os.Setenv("service_httpVersion", "1")
+	_ = os.Setenv("SERVICE_MAXBODYSIZE", "0")
+	defer func() {
+		_ = os.Unsetenv("service_httpVersion")
+		_ = os.Unsetenv("SERVICE_MAXBODYSIZE")
+	}()
+	s.ResetAllSets()
+
+	s.Restful(0, "POST", "/size", func(in struct{ Name string }) int {
+		return len(in.Name)
+	})
+
+	as := s.AsyncStart()
+	defer as.Stop()
+
+	// maxBodySize 为 0 时不限制，可以超过默认的 10MB
+	size := 11 * 1024 * 1024
+	res, data := postRaw("http://"+as.Addr+"/size", "application/json", []byte(`{"name":"`+strings.Repeat("x", size)+`"}`))
+	t.Test(res != nil && res.StatusCode == 200 && string(data) == strconv.Itoa(size), "unlimited body", res, len(data))
+}
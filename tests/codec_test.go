@@ -0,0 +1,141 @@
+package tests
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ssgo/s"
+)
+
+func postRaw(url, contentType string, body []byte) (*http.Response, []byte) {
+	res, err := http.Post(url, contentType, bytes.NewReader(body))
+	if err != nil {
+		return nil, []byte(err.Error())
+	}
+	data, _ := ioutil.ReadAll(res.Body)
+	_ = res.Body.Close()
+	return res, data
+}
+
+func TestCodec(tt *testing.T) {
+	t := s.T(tt)
+
+	_ = os.Setenv("LOG_FILE", os.DevNull)
+	_ = os.Setenv("service_httpVersion", "1")
+	defer func() {
+		_ = os.Unsetenv("service_httpVersion")
+	}()
+	s.ResetAllSets()
+
+	s.Restful(0, "POST", "/user", func(in struct {
+		Name string
+		Age  int
+		Tags []string
+	}) s.Map {
+		return s.Map{"name": in.Name, "age": in.Age, "tags": in.Tags}
+	})
+	s.Restful(0, "GET", "/keys", func() s.Map {
+		return s.Map{"ok": 1, "a><b": 2, "1st": 3}
+	}).SetFormats("json", "xml")
+
+	// 每行一个 k=v
+	s.RegisterCodec("text/x-kv", func(data []byte, v interface{}) error {
+		m := map[string]interface{}{}
+		for _, line := range strings.Split(string(data), "\n") {
+			if pos := strings.IndexByte(line, '='); pos > 0 {
+				m[line[0:pos]] = line[pos+1:]
+			}
+		}
+		*(v.(*interface{})) = m
+		return nil
+	}, func(v interface{}) ([]byte, error) {
+		m := v.(s.Map)
+		return []byte("name=" + m["name"].(string)), nil
+	})
+
+	as := s.AsyncStart()
+	defer as.Stop()
+
+	res, data := postRaw("http://"+as.Addr+"/user", "application/xml; charset=utf-8", []byte(`<user><name>Tom</name><age>20</age><tags>a</tags><tags>b</tags></user>`))
+	t.Test(res != nil && res.StatusCode == 200 && res.Header.Get("Content-Type") == "application/xml", "xml response", res, string(data))
+	t.Test(strings.HasSuffix(string(data), "<result><age>20</age><name>Tom</name><tags>a</tags><tags>b</tags></result>"), "xml codec", string(data))
+
+	res, data = postRaw("http://"+as.Addr+"/user", "application/xml", []byte(`<user><name>Tom`))
+	t.Test(res != nil && res.StatusCode == 400, "bad xml", res)
+
+	res, data = getRaw("http://"+as.Addr+"/keys", "Accept", "application/xml")
+	t.Test(strings.HasSuffix(string(data), `<result><entry key="1st">3</entry><entry key="a&gt;&lt;b">2</entry><ok>1</ok></result>`), "xml invalid names", string(data))
+
+	// {"name": "Tom", "age": 20}
+	msgpackIn := append(append([]byte{0x82, 0xa4}, "name"...), append(append([]byte{0xa3}, "Tom"...), append([]byte{0xa3}, "age"...)...)...)
+	msgpackIn = append(msgpackIn, 0x14)
+	res, data = postRaw("http://"+as.Addr+"/user", "application/msgpack", msgpackIn)
+	// {"age": 20, "name": "Tom", "tags": nil}
+	msgpackOut := append(append([]byte{0x83, 0xa3}, "age"...), 0x14, 0xa4)
+	msgpackOut = append(append(msgpackOut, "name"...), 0xa3)
+	msgpackOut = append(append(msgpackOut, "Tom"...), 0xa4)
+	msgpackOut = append(append(msgpackOut, "tags"...), 0xc0)
+	t.Test(res != nil && res.StatusCode == 200 && res.Header.Get("Content-Type") == "application/msgpack", "msgpack response", res)
+	t.Test(bytes.Equal(data, msgpackOut), "msgpack codec", data, msgpackOut)
+
+	res, data = postRaw("http://"+as.Addr+"/user", "text/x-kv", []byte("name=Jerry\nage=3"))
+	t.Test(res != nil && res.StatusCode == 200 && string(data) == "name=Jerry", "custom codec", string(data))
+
+	d := as.Post("/user", s.Map{"name": "Tom", "age": 20}).Map()
+	t.Test(d["name"] == "Tom" && d["age"] == float64(20), "json codec", d)
+}
+
+func TestCodecDepth(tt *testing.T) {
+	t := s.T(tt)
+
+	_ = os.Setenv("LOG_FILE", os.DevNull)
+	_ = os.Setenv("service_httpVersion", "1")
+	_ = os.Setenv("SERVICE_MAXJSONDEPTH", "3")
+	defer func() {
+		_ = os.Unsetenv("service_httpVersion")
+		_ = os.Unsetenv("SERVICE_MAXJSONDEPTH")
+	}()
+	s.ResetAllSets()
+
+	s.Restful(0, "POST", "/echo", func(in struct{ A interface{} }) interface{} {
+		return in.A
+	})
+
+	// 每个字符嵌套一层
+	s.RegisterCodec("text/x-nest", func(data []byte, v interface{}) error {
+		var value interface{} = "end"
+		for range data {
+			value = map[string]interface{}{"a": value}
+		}
+		*(v.(*interface{})) = value
+		return nil
+	}, nil)
+
+	as := s.AsyncStart()
+	defer as.Stop()
+
+	// {"a":[[1]]}
+	msgpackIn := append([]byte{0x81, 0xa1, 'a', 0x91, 0x91}, 0x01)
+	res, data := postRaw("http://"+as.Addr+"/echo", "application/msgpack", msgpackIn)
+	t.Test(res != nil && res.StatusCode == 200, "msgpack in depth", res, data)
+
+	// 大量嵌套的数组
+	res, data = postRaw("http://"+as.Addr+"/echo", "application/msgpack", append(bytes.Repeat([]byte{0x91}, 1000000), 0xc0))
+	t.Test(res != nil && res.StatusCode == 400, "msgpack too deep", res, string(data))
+
+	res, data = postRaw("http://"+as.Addr+"/echo", "application/xml", []byte(`<r><a><b>1</b></a></r>`))
+	t.Test(res != nil && res.StatusCode == 200, "xml in depth", res, string(data))
+
+	res, data = postRaw("http://"+as.Addr+"/echo", "application/xml", []byte(strings.Repeat("<a>", 1000000)))
+	t.Test(res != nil && res.StatusCode == 400, "xml too deep", res, string(data))
+
+	res, data = postRaw("http://"+as.Addr+"/echo", "text/x-nest", []byte("xx"))
+	t.Test(res != nil && res.StatusCode == 200, "custom codec in depth", res, string(data))
+
+	res, data = postRaw("http://"+as.Addr+"/echo", "text/x-nest", []byte("xxxx"))
+	t.Test(res != nil && res.StatusCode == 400, "custom codec too deep", res, string(data))
+}
@@ -0,0 +1,123 @@
+package tests
+
+import (
+	"errors"
+	"net"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ssgo/s"
+)
+
+// 以 u 开头的用户编号，例如 u123
+type UserId int64
+
+// 以 o 开头的订单编号，服务启动后注册转换器
+type OrderId int64
+
+// 两位小数的金额，例如 12.34
+type Money struct {
+	Cents int64
+}
+
+func (m *Money) UnmarshalText(text []byte) error {
+	parts := strings.SplitN(string(text), ".", 2)
+	if len(parts) != 2 || len(parts[1]) != 2 {
+		return errors.New("bad money")
+	}
+	yuan, err1 := strconv.ParseInt(parts[0], 10, 64)
+	cents, err2 := strconv.ParseInt(parts[1], 10, 64)
+	if err1 != nil || err2 != nil {
+		return errors.New("bad money")
+	}
+	m.Cents = yuan*100 + cents
+	return nil
+}
+
+func TestConverter(tt *testing.T) {
+	t := s.T(tt)
+
+	_ = os.Setenv("LOG_FILE", os.DevNull)
+	s.ResetAllSets()
+
+	s.RegisterConverter(reflect.TypeOf(UserId(0)), func(value interface{}) (interface{}, error) {
+		str, _ := value.(string)
+		if !strings.HasPrefix(str, "u") {
+			return nil, errors.New("bad user id")
+		}
+		n, err := strconv.ParseInt(str[1:], 10, 64)
+		return UserId(n), err
+	})
+	s.Register(0, "/convert", func(in struct {
+		At    time.Time
+		Wait  time.Duration
+		Ip    net.IP
+		User  UserId
+		Price *Money
+		Days  []time.Time
+		Name  string
+		Range struct{ From time.Time }
+	}) s.Map {
+		days := make([]string, len(in.Days))
+		for i, d := range in.Days {
+			days[i] = d.Format("2006-01-02")
+		}
+		price := int64(-1)
+		if in.Price != nil {
+			price = in.Price.Cents
+		}
+		return s.Map{"at": in.At.UTC().Format(time.RFC3339), "wait": in.Wait.String(), "ip": in.Ip.String(), "user": int64(in.User),
+			"price": price, "days": strings.Join(days, ","), "name": in.Name, "from": in.Range.From.Format("2006-01-02")}
+	})
+
+	s.Register(0, "/order", func(in struct{ Order OrderId }) int64 {
+		return int64(in.Order)
+	})
+
+	as := s.AsyncStart()
+	defer as.Stop()
+
+	d := as.Post("/convert", s.Map{"at": "2024-05-06T07:08:09Z", "wait": "1m30s", "ip": "10.0.0.1", "user": "u42", "price": "12.34",
+		"days": []string{"2024-01-01", "2024-01-02"}, "name": "Tom", "range": s.Map{"from": "2024-03-01"}}).Map()
+	t.Test(d["at"] == "2024-05-06T07:08:09Z" && d["wait"] == "1m30s" && d["ip"] == "10.0.0.1", "time and text unmarshaler", d)
+	t.Test(d["user"] == float64(42) && d["price"] == float64(1234) && d["name"] == "Tom", "registered converter", d)
+	t.Test(d["days"] == "2024-01-01,2024-01-02" && d["from"] == "2024-03-01", "slices and nested", d)
+
+	d = as.Get("/convert?at=1714979289&wait=500").Map()
+	t.Test(d["at"] == "2024-05-06T07:08:09Z" && d["wait"] == "500ms" && d["price"] == float64(-1), "timestamp and milliseconds", d)
+
+	r := as.Post("/convert", s.Map{"at": "yesterday", "ip": "10.0.0", "user": "42", "price": "1.5"})
+	d = r.Map()
+	fields := map[string]bool{}
+	list, _ := d["fields"].([]interface{})
+	for _, f := range list {
+		if fm, ok := f.(map[string]interface{}); ok && fm["rule"] == "type" {
+			field, _ := fm["field"].(string)
+			fields[field] = true
+		}
+	}
+	t.Test(r.Response.StatusCode == 400 && fields["at"] && fields["ip"] && fields["user"] && fields["price"], "field errors", r.Response.StatusCode, r.String())
+
+	// 服务启动后注册转换器，与并发的请求不冲突
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			as.Get("/order?order=1")
+		}()
+	}
+	s.RegisterConverter(reflect.TypeOf(OrderId(0)), func(value interface{}) (interface{}, error) {
+		str, _ := value.(string)
+		n, err := strconv.ParseInt(strings.TrimPrefix(str, "o"), 10, 64)
+		return OrderId(n), err
+	})
+	wg.Wait()
+	r = as.Get("/order?order=o123")
+	t.Test(r.String() == "123", "register after start", r.String())
+}
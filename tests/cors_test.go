@@ -0,0 +1,79 @@
+package tests
+
+import (
+	"os"
+	"testing"
+
+	"github.com/ssgo/s"
+)
+
+func TestCors(tt *testing.T) {
+	t := s.T(tt)
+
+	_ = os.Setenv("LOG_FILE", os.DevNull)
+	_ = os.Setenv("SERVICE_CORS_ALLOWORIGINS", "https://app.abc.com, https://*.test.abc.com, ^http://localhost:\\d+$")
+	_ = os.Setenv("SERVICE_CORS_EXPOSEHEADERS", "X-Total")
+	_ = os.Setenv("SERVICE_CORS_MAXAGE", "600")
+	defer func() {
+		_ = os.Unsetenv("SERVICE_CORS_ALLOWORIGINS")
+		_ = os.Unsetenv("SERVICE_CORS_EXPOSEHEADERS")
+		_ = os.Unsetenv("SERVICE_CORS_MAXAGE")
+	}()
+	s.ResetAllSets()
+
+	s.Restful(0, "GET", "/users", func() string {
+		return "users"
+	})
+	s.Restful(0, "POST", "/users", func() string {
+		return "add"
+	})
+	s.Restful(0, "GET", "/open", func() string {
+		return "open"
+	}).SetCors(s.CorsConfig{AllowOrigins: "*", AllowMethods: "GET", AllowHeaders: "Token"})
+
+	s.Restful(0, "GET", "/credentials", func() string {
+		return "credentials"
+	}).SetCors(s.CorsConfig{AllowOrigins: "*", AllowCredentials: true})
+
+	private := s.Group("/private", 0)
+	private.SetCors(s.CorsConfig{})
+	private.Register(0, "/info", func() string {
+		return "info"
+	})
+
+	as := s.AsyncStart()
+	defer as.Stop()
+
+	r := as.Get("/users", "Origin", "https://app.abc.com")
+	t.Test(r.String() == "users" && r.Response.Header.Get("Access-Control-Allow-Origin") == "https://app.abc.com", "allowed origin", r.Response.Header)
+	t.Test(r.Response.Header.Get("Access-Control-Expose-Headers") == "X-Total", "expose headers", r.Response.Header)
+
+	r = as.Get("/users", "Origin", "https://a.test.abc.com")
+	t.Test(r.Response.Header.Get("Access-Control-Allow-Origin") == "https://a.test.abc.com", "wildcard origin", r.Response.Header)
+
+	r = as.Get("/users", "Origin", "http://localhost:8080")
+	t.Test(r.Response.Header.Get("Access-Control-Allow-Origin") == "http://localhost:8080", "regex origin", r.Response.Header)
+
+	r = as.Get("/users", "Origin", "https://evil.com")
+	t.Test(r.String() == "users" && r.Response.Header.Get("Access-Control-Allow-Origin") == "", "not allowed origin", r.Response.Header)
+
+	r = as.Do("OPTIONS", "/users", nil, "Origin", "https://app.abc.com", "Access-Control-Request-Method", "POST", "Access-Control-Request-Headers", "Content-Type")
+	t.Test(r.Response.StatusCode == 204 && r.Response.Header.Get("Access-Control-Allow-Origin") == "https://app.abc.com", "preflight", r.Response.StatusCode, r.Response.Header)
+	t.Test(r.Response.Header.Get("Access-Control-Allow-Methods") == "GET, HEAD, OPTIONS, POST" && r.Response.Header.Get("Access-Control-Allow-Headers") == "Content-Type" && r.Response.Header.Get("Access-Control-Max-Age") == "600", "preflight headers", r.Response.Header)
+
+	r = as.Do("OPTIONS", "/users", nil, "Origin", "https://evil.com", "Access-Control-Request-Method", "POST")
+	t.Test(r.Response.StatusCode == 403 && r.Response.Header.Get("Access-Control-Allow-Origin") == "", "preflight not allowed origin", r.Response.StatusCode, r.Response.Header)
+
+	r = as.Do("OPTIONS", "/open", nil, "Origin", "https://evil.com", "Access-Control-Request-Method", "GET", "Access-Control-Request-Headers", "token")
+	t.Test(r.Response.StatusCode == 204 && r.Response.Header.Get("Access-Control-Allow-Origin") == "*" && r.Response.Header.Get("Access-Control-Allow-Headers") == "Token", "route cors", r.Response.StatusCode, r.Response.Header)
+
+	r = as.Do("OPTIONS", "/open", nil, "Origin", "https://evil.com", "Access-Control-Request-Method", "GET", "Access-Control-Request-Headers", "Other")
+	t.Test(r.Response.StatusCode == 403, "preflight not allowed header", r.Response.StatusCode)
+
+	// 任意来源时不返回 Allow-Credentials，也不回显 Origin
+	r = as.Get("/credentials", "Origin", "https://evil.com")
+	t.Test(r.Response.Header.Get("Access-Control-Allow-Origin") == "*" && r.Response.Header.Get("Access-Control-Allow-Credentials") == "", "any origin without credentials", r.Response.Header)
+
+	r = as.Get("/private/info", "Origin", "https://app.abc.com")
+	t.Test(r.String() == "info" && r.Response.Header.Get("Access-Control-Allow-Origin") == "", "group cors", r.Response.Header)
+}
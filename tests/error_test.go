@@ -0,0 +1,72 @@
+package tests
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/ssgo/s"
+)
+
+var errNotFound = errors.New("record not found")
+
+func TestHTTPError(tt *testing.T) {
+	t := s.T(tt)
+
+	_ = os.Setenv("LOG_FILE", os.DevNull)
+	s.ResetAllSets()
+
+	s.SetErrorMapper(func(err error) *s.HTTPError {
+		if err == errNotFound {
+			return s.NewHTTPError(404, "")
+		}
+		return nil
+	})
+	s.Restful(0, "GET", "/users/{id}", func(in struct{ Id int }) (s.Map, error) {
+		switch in.Id {
+		case 1:
+			return s.Map{"id": 1}, nil
+		case 2:
+			return nil, &s.HTTPError{Status: 403, Message: "no access", Data: s.Map{"id": 2}}
+		case 3:
+			return nil, errNotFound
+		case 4:
+			return nil, fmt.Errorf("load user: %w", s.NewHTTPError(409, "conflict"))
+		}
+		return nil, errors.New("db error")
+	})
+	s.Restful(0, "DELETE", "/users/{id}", func(in struct{ Id int }) error {
+		if in.Id == 1 {
+			return nil
+		}
+		return s.NewHTTPError(400, "bad id")
+	})
+
+	as := s.AsyncStart()
+	defer as.Stop()
+
+	r := as.Get("/users/1")
+	t.Test(r.Response.StatusCode == 200 && r.Map()["id"] == float64(1), "nil error", r.Response.StatusCode, r.String())
+
+	r = as.Get("/users/2")
+	d := r.Map()
+	data, _ := d["data"].(map[string]interface{})
+	t.Test(r.Response.StatusCode == 403 && d["status"] == float64(403) && d["message"] == "no access" && data["id"] == float64(2), "http error", r.Response.StatusCode, r.String())
+
+	r = as.Get("/users/3")
+	d = r.Map()
+	t.Test(r.Response.StatusCode == 404 && d["message"] == "Not Found" && d["data"] == nil, "error mapper", r.Response.StatusCode, r.String())
+
+	r = as.Get("/users/4")
+	t.Test(r.Response.StatusCode == 409 && r.Map()["message"] == "conflict", "wrapped http error", r.Response.StatusCode, r.String())
+
+	r = as.Get("/users/5")
+	t.Test(r.Response.StatusCode == 500 && r.Map()["message"] == "Internal Server Error", "unknown error", r.Response.StatusCode, r.String())
+
+	r = as.Do("DELETE", "/users/1", nil)
+	t.Test(r.Response.StatusCode == 200, "error only return", r.Response.StatusCode, r.String())
+
+	r = as.Do("DELETE", "/users/2", nil)
+	t.Test(r.Response.StatusCode == 400 && r.Map()["message"] == "bad id", "error only return with error", r.Response.StatusCode, r.String())
+}
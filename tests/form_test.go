@@ -0,0 +1,58 @@
+package tests
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/ssgo/s"
+)
+
+type formFilter struct {
+	Status string
+	Tags   []string
+}
+
+type formItem struct {
+	Name string
+	Num  int
+}
+
+func TestNestedForm(tt *testing.T) {
+	t := s.T(tt)
+
+	_ = os.Setenv("LOG_FILE", os.DevNull)
+	_ = os.Setenv("service_httpVersion", "1")
+	defer func() {
+		_ = os.Unsetenv("service_httpVersion")
+	}()
+	s.ResetAllSets()
+
+	s.Register(0, "/search", func(in struct {
+		Filter formFilter
+		Sort   []string
+		Items  []formItem
+		Page   struct{ Size int }
+	}) interface{} {
+		return in
+	})
+
+	as := s.AsyncStart()
+	defer as.Stop()
+
+	d := as.Get("/search?filter[status]=open&filter[tags][]=a&filter[tags][]=b&sort[]=name&sort[]=id&items[1][name]=y&items[0][name]=x&items[0][num]=3&page.size=20").Map()
+	filter, _ := d["filter"].(map[string]interface{})
+	items, _ := d["items"].([]interface{})
+	page, _ := d["page"].(map[string]interface{})
+	t.Test(filter["status"] == "open" && len(filter["tags"].([]interface{})) == 2, "bracket keys in query", d)
+	t.Test(len(d["sort"].([]interface{})) == 2 && d["sort"].([]interface{})[1] == "id", "array keys in query", d)
+	t.Test(len(items) == 2 && items[0].(map[string]interface{})["name"] == "x" && items[0].(map[string]interface{})["num"] == float64(3) && items[1].(map[string]interface{})["name"] == "y", "indexed keys in query", d)
+	t.Test(page["size"] == float64(20), "dot keys in query", d)
+
+	res, data := postRaw("http://"+as.Addr+"/search", "application/x-www-form-urlencoded", []byte("filter%5Bstatus%5D=closed&sort%5B%5D=a&page.size=5"))
+	d = map[string]interface{}{}
+	_ = json.Unmarshal(data, &d)
+	filter, _ = d["filter"].(map[string]interface{})
+	page, _ = d["page"].(map[string]interface{})
+	t.Test(res != nil && res.StatusCode == 200 && filter["status"] == "closed" && page["size"] == float64(5) && len(d["sort"].([]interface{})) == 1, "nested keys in body", string(data))
+}
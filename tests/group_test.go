@@ -0,0 +1,54 @@
+package tests
+
+import (
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/ssgo/s"
+)
+
+func TestGroup(tt *testing.T) {
+	t := s.T(tt)
+
+	_ = os.Setenv("LOG_FILE", os.DevNull)
+	s.ResetAllSets()
+
+	s.Register(0, "/public", Echo4)
+
+	admin := s.Group("/admin", 1)
+	admin.SetAuthChecker(func(authLevel int, url *string, in *map[string]interface{}, request *http.Request) bool {
+		return request.Header.Get("Token") == "admin"
+	})
+	admin.SetInFilter(func(in *map[string]interface{}, request *http.Request, response *http.ResponseWriter) interface{} {
+		(*in)["group"] = "admin"
+		return nil
+	})
+	admin.Register(0, "/", Echo4)
+
+	users := admin.Group("users", 0)
+	users.SetOutFilter(func(in *map[string]interface{}, request *http.Request, response *http.ResponseWriter, out interface{}) (interface{}, bool) {
+		result := out.(s.Map)
+		result["filtered"] = true
+		return result, false
+	})
+	users.Restful(0, "GET", "/{id:int}", Echo4)
+
+	as := s.AsyncStart()
+	defer as.Stop()
+
+	d := as.Get("/public").Map()
+	t.Test(d["group"] == nil && d["filtered"] == nil, "filters not applied outside group", d)
+
+	r := as.Get("/admin")
+	t.Test(r.Response.StatusCode == 403, "group auth level", r.Response.StatusCode)
+
+	d = as.Get("/admin", "Token", "admin").Map()
+	t.Test(d["group"] == "admin" && d["filtered"] == nil, "group in filter", d)
+
+	r = as.Get("/admin/users/12")
+	t.Test(r.Response.StatusCode == 403, "nested group auth level", r.Response.StatusCode)
+
+	d = as.Get("/admin/users/12", "Token", "admin").Map()
+	t.Test(d["group"] == "admin" && d["filtered"] == true && d["id"] == float64(12), "nested group filters", d)
+}
@@ -0,0 +1,68 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ssgo/s"
+)
+
+func TestHost(tt *testing.T) {
+	t := s.T(tt)
+
+	_ = os.Setenv("LOG_FILE", os.DevNull)
+	s.ResetAllSets()
+	s.Register(0, "/info", func() string {
+		return "default"
+	})
+	s.RestfulWithHost("api.abc.com", 0, "GET", "/info", func() string {
+		return "api"
+	})
+	s.RegisterWithHost("{tenant}.abc.com", 0, "/info", func(in struct{ Tenant string }) string {
+		return "tenant " + in.Tenant
+	})
+	s.RegisterWithHost("*.test.abc.com", 0, "/only", func() string {
+		return "test"
+	})
+	s.Register(0, "/who/{name}", func(in struct{ Name string }) string {
+		return "who " + in.Name
+	})
+	s.RewriteWithHost("{tenant}.abc.com", "/me", "/who/{tenant}")
+	wwwPath, _ := filepath.Abs("www")
+	s.StaticWithHost("static.abc.com", "/", wwwPath)
+
+	as := s.AsyncStart()
+	defer as.Stop()
+
+	r := as.Get("/info")
+	t.Test(r.String() == "default", "default host", r.String())
+
+	r = as.Get("/info", "X-Host", "api.abc.com")
+	t.Test(r.String() == "api", "exact host", r.String())
+
+	r = as.Get("/info", "X-Host", "Foo.abc.com:8080")
+	t.Test(r.String() == "tenant foo", "host args", r.String())
+
+	r = as.Get("/info", "X-Host", "a.b.abc.com")
+	t.Test(r.String() == "default", "host arg not match multiple labels", r.String())
+
+	r = as.Get("/only", "X-Host", "a.b.test.abc.com")
+	t.Test(r.String() == "test", "wildcard subdomains", r.String())
+
+	r = as.Get("/only")
+	t.Test(r.Response.StatusCode == 404, "host only route", r.Response.StatusCode)
+
+	r = as.Get("/me", "X-Host", "bar.abc.com")
+	t.Test(r.String() == "who bar", "rewrite with host", r.String())
+
+	r = as.Get("/me")
+	t.Test(r.Response.StatusCode == 404, "rewrite only for host", r.Response.StatusCode)
+
+	r = as.Get("/aaa/111.json", "X-Host", "static.abc.com")
+	t.Test(r.Response.StatusCode == 200 && strings.TrimSpace(r.String()) == "111", "static with host", r.Response.StatusCode, r.String())
+
+	r = as.Get("/aaa/111.json")
+	t.Test(r.Response.StatusCode == 404, "static only for host", r.Response.StatusCode)
+}
@@ -0,0 +1,155 @@
+package tests
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ssgo/log"
+	"github.com/ssgo/s"
+	"github.com/ssgo/standard"
+)
+
+type Greeter interface {
+	Greet(name string) string
+}
+
+type helloGreeter struct{}
+
+func (g *helloGreeter) Greet(name string) string {
+	return "hello " + name
+}
+
+type RequestInfo struct {
+	Id     int32
+	Logger *log.Logger
+}
+
+type TraceService struct {
+	logger *log.Logger
+}
+
+func (ts *TraceService) CopyByLogger(logger *log.Logger) *TraceService {
+	return &TraceService{logger: logger}
+}
+
+// 日志保存在共用的指针中，与 ssgo/db 的 DB 相同
+type clientLogger struct {
+	logger *log.Logger
+}
+
+type PooledClient struct {
+	pool   *int32
+	logger *clientLogger
+}
+
+func (c *PooledClient) SetLogger(logger *log.Logger) {
+	c.logger.logger = logger
+}
+
+func (c *PooledClient) CopyByLogger(logger *log.Logger) *PooledClient {
+	return &PooledClient{pool: c.pool, logger: &clientLogger{logger: logger}}
+}
+
+type Broken struct{}
+
+type CurrentUser struct{ Name string }
+
+type UserProfile struct{ Title string }
+
+func TestProviders(tt *testing.T) {
+	t := s.T(tt)
+
+	_ = os.Setenv("LOG_FILE", os.DevNull)
+	s.ResetAllSets()
+
+	var singletons, requests int32
+	s.SetProvider(s.InjectSingleton, func() Greeter {
+		atomic.AddInt32(&singletons, 1)
+		return &helloGreeter{}
+	})
+	s.SetProvider(s.InjectPerRequest, func(request *http.Request, logger *log.Logger) *RequestInfo {
+		return &RequestInfo{Id: atomic.AddInt32(&requests, 1), Logger: logger}
+	})
+	s.SetProvider(s.InjectPerRequest, func() (*Broken, error) {
+		return nil, errors.New("connect failed")
+	})
+	// provider 中读取 Session 对象
+	s.SetProvider(s.InjectPerRequest, func(request *http.Request) *UserProfile {
+		user, _ := s.GetSessionInject(request, reflect.TypeOf(&CurrentUser{})).(*CurrentUser)
+		if user == nil {
+			return &UserProfile{}
+		}
+		return &UserProfile{Title: "Mr. " + user.Name}
+	})
+	s.SetInject(&TraceService{})
+	shared := &PooledClient{pool: new(int32), logger: &clientLogger{logger: log.DefaultLogger}}
+	s.SetInject(shared)
+	s.SetInFilter(func(in *map[string]interface{}, request *http.Request, response *http.ResponseWriter) interface{} {
+		s.SetSessionInject(request, &CurrentUser{Name: "Tom"})
+		return nil
+	})
+
+	s.Register(0, "/greet", func(g Greeter, info1, info2 *RequestInfo, user *CurrentUser) s.Map {
+		return s.Map{"greet": g.Greet(user.Name), "same": info1 == info2, "id": info1.Id, "traceId": info1.Logger.GetTraceId()}
+	})
+	s.Register(0, "/trace", func(request *http.Request, ts *TraceService) bool {
+		return ts.logger.GetTraceId() == request.Header.Get(standard.DiscoverHeaderRequestId)
+	})
+	s.Register(0, "/client", func(request *http.Request, client *PooledClient) bool {
+		time.Sleep(10 * time.Millisecond)
+		return client.logger.logger.GetTraceId() == request.Header.Get(standard.DiscoverHeaderRequestId)
+	})
+	s.Register(0, "/profile", func(profile *UserProfile) string {
+		return profile.Title
+	})
+	s.Register(0, "/broken", func(b *Broken) string {
+		return "ok"
+	})
+
+	as := s.AsyncStart()
+	defer as.Stop()
+
+	d := as.Get("/greet").Map()
+	t.Test(d["greet"] == "hello Tom" && d["same"] == true && d["traceId"] != "", "per request provider", d)
+	d2 := as.Get("/greet").Map()
+	t.Test(d2["id"] != d["id"] && atomic.LoadInt32(&singletons) == 1, "singleton provider", d, d2, singletons)
+
+	wg := sync.WaitGroup{}
+	failed := int32(0)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if as.Get("/trace").String() != "true" {
+				atomic.AddInt32(&failed, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	t.Test(failed == 0, "logger per request", failed)
+
+	failed = 0
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if as.Get("/client").String() != "true" {
+				atomic.AddInt32(&failed, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	t.Test(failed == 0 && shared.logger.logger == log.DefaultLogger, "copy by logger", failed)
+
+	r := as.Get("/profile")
+	t.Test(r.String() == "Mr. Tom", "provider reads session inject", r.String())
+
+	r = as.Get("/broken")
+	t.Test(r.Response.StatusCode == 500, "provider error", r.Response.StatusCode, r.String())
+}
@@ -53,10 +53,14 @@ func TestInject(tt *testing.T) {
 	t := s.T(tt)
 
 	type RedisA = *redis.Redis
-	s.SetInject(RedisA(redis.GetRedis("test", nil)))
+	s.SetProvider(s.InjectPerRequest, func(logger *log.Logger) RedisA {
+		return redis.GetRedis("test", logger)
+	})
 
 	type DBA = *db.DB
-	s.SetInject(DBA(db.GetDB("test", nil)))
+	s.SetProvider(s.InjectPerRequest, func(logger *log.Logger) DBA {
+		return db.GetDB("test", logger)
+	})
 
 	s.Register(0, "/redis", func(request *http.Request, rd RedisA) TestLoggerInjectResult {
 		return TestLoggerInjectResult{
@@ -92,6 +96,10 @@ func (ctx *Context) SetLogger(logger *log.Logger) {
 	ctx.logger = logger
 }
 
+func (ctx *Context) CopyByLogger(logger *log.Logger) *Context {
+	return &Context{logger: logger}
+}
+
 func (ctx *Context) GetRedis() *redis.Redis {
 	return redis.GetRedis("text", ctx.logger)
 }
@@ -0,0 +1,55 @@
+package tests
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ssgo/s"
+)
+
+func TestMethods(tt *testing.T) {
+	t := s.T(tt)
+
+	_ = os.Setenv("LOG_FILE", os.DevNull)
+	s.ResetAllSets()
+	s.Restful(0, "GET", "/users", func() string {
+		return "list users"
+	})
+	s.Restful(0, "POST", "/users", func() string {
+		return "add user"
+	})
+	s.Restful(0, "DELETE", "/users/{id:int}", func(in struct{ Id int }) string {
+		return "delete user"
+	})
+	s.Register(0, "/any", func() string {
+		return "any"
+	})
+	as := s.AsyncStart()
+	defer as.Stop()
+
+	r := as.Put("/users", nil)
+	t.Test(r.Response.StatusCode == 405, "method not allowed", r.Response.StatusCode)
+	t.Test(r.Response.Header.Get("Allow") == "GET, HEAD, OPTIONS, POST", "allow header", r.Response.Header.Get("Allow"))
+
+	r = as.Do("OPTIONS", "/users", nil)
+	t.Test(r.Response.StatusCode == 204 && r.Response.Header.Get("Allow") == "GET, HEAD, OPTIONS, POST", "auto options", r.Response.StatusCode, r.Response.Header)
+
+	r = as.Get("/users/12")
+	t.Test(r.Response.StatusCode == 405 && r.Response.Header.Get("Allow") == "DELETE, OPTIONS", "method not allowed with args", r.Response.StatusCode, r.Response.Header)
+
+	r = as.Delete("/users/abc", nil)
+	t.Test(r.Response.StatusCode == 404, "not found with bad args", r.Response.StatusCode)
+
+	r = as.Head("/users", nil)
+	t.Test(r.Response.StatusCode == 200 && r.Response.ContentLength == int64(len("list users")) && r.String() == "", "head from get", r.Response.StatusCode, r.Response.ContentLength, r.String())
+
+	r = as.Head("/users", nil, "Accept-Encoding", "gzip")
+	t.Test(r.Response.StatusCode == 200 && r.String() == "", "head without body", r.Response.StatusCode, r.String())
+
+	r = as.Do("PATCH", "/any", nil)
+	t.Test(r.String() == "any", "any method", r.String())
+
+	r = as.Get("/none")
+	t.Test(r.Response.StatusCode == 404 && !strings.Contains(r.Response.Header.Get("Allow"), "GET"), "not found", r.Response.StatusCode)
+}
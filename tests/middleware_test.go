@@ -0,0 +1,97 @@
+package tests
+
+import (
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/ssgo/s"
+)
+
+func TestMiddleware(tt *testing.T) {
+	t := s.T(tt)
+
+	_ = os.Setenv("LOG_FILE", os.DevNull)
+	s.ResetAllSets()
+
+	steps := make([]string, 0)
+	s.Use(func(ctx *s.Context, next func()) {
+		steps = append(steps, "global in")
+		ctx.Response.Header().Set("Global", "1")
+		next()
+		steps = append(steps, "global out")
+	})
+	s.Use(func(ctx *s.Context, next func()) {
+		defer func() {
+			if err := recover(); err != nil {
+				ctx.Response.WriteHeader(500)
+				_, _ = ctx.Response.Write([]byte("recovered"))
+			}
+		}()
+		next()
+	})
+	s.SetInFilter(func(in *map[string]interface{}, request *http.Request, response *http.ResponseWriter) interface{} {
+		steps = append(steps, "in filter")
+		return nil
+	})
+	s.SetOutFilter(func(in *map[string]interface{}, request *http.Request, response *http.ResponseWriter, out interface{}) (interface{}, bool) {
+		steps = append(steps, "out filter")
+		return nil, false
+	})
+
+	s.Register(0, "/hello", func() string {
+		steps = append(steps, "service")
+		return "hello"
+	}).Use(func(ctx *s.Context, next func()) {
+		steps = append(steps, "route in "+ctx.Path())
+		next()
+		ctx.Result = ctx.Result.(string) + " world"
+	})
+	s.Register(0, "/panic", func() string {
+		panic("oops")
+	})
+	s.Register(1, "/private", func() string {
+		return "private"
+	}).Use(func(ctx *s.Context, next func()) {
+		if ctx.Request.Header.Get("Stop") == "1" {
+			ctx.Response.WriteHeader(401)
+			return
+		}
+		next()
+	})
+
+	api := s.Group("/api", 0).Use(func(ctx *s.Context, next func()) {
+		ctx.Response.Header().Set("Group", "api")
+		next()
+	})
+	api.Register(0, "/echo", func() string {
+		return "api"
+	})
+
+	as := s.AsyncStart()
+	defer as.Stop()
+
+	steps = steps[:0]
+	r := as.Get("/hello")
+	t.Test(r.String() == "hello world" && r.Response.Header.Get("Global") == "1", "wrap service", r.String(), r.Response.Header)
+	t.Test(len(steps) == 6 && steps[0] == "global in" && steps[1] == "route in /hello" && steps[2] == "in filter" && steps[3] == "service" && steps[4] == "out filter" && steps[5] == "global out", "middleware order", steps)
+
+	r = as.Get("/panic")
+	t.Test(r.Response.StatusCode == 500 && r.String() == "recovered", "recover in middleware", r.Response.StatusCode, r.String())
+
+	r = as.Get("/private", "Stop", "1")
+	t.Test(r.Response.StatusCode == 401, "stop in route middleware", r.Response.StatusCode)
+
+	r = as.Get("/private")
+	t.Test(r.Response.StatusCode == 403, "auth after route middleware", r.Response.StatusCode)
+
+	r = as.Get("/api/echo")
+	t.Test(r.String() == "api" && r.Response.Header.Get("Group") == "api", "group middleware", r.String(), r.Response.Header)
+
+	r = as.Get("/hello")
+	t.Test(r.Response.Header.Get("Group") == "", "group middleware not applied outside group", r.Response.Header)
+
+	steps = steps[:0]
+	r = as.Get("/not-found")
+	t.Test(r.Response.StatusCode == 404 && len(steps) == 2, "global middleware wrap 404", r.Response.StatusCode, steps)
+}
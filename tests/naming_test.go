@@ -0,0 +1,146 @@
+package tests
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/ssgo/s"
+)
+
+type NamingBase struct {
+	CreateTime string
+}
+
+type namingUser struct {
+	UserID   int
+	URLPath  string
+	NickName string `json:"nick"`
+	Secret   string `json:"-"`
+	Remark   string `json:",omitempty"`
+	NamingBase
+	Tag string `json:"Label"`
+}
+
+// 指针上的 MarshalJSON、MarshalText，数组的元素和指针指向的结构体的字段与 encoding/json 一样使用
+type namingPoint struct {
+	X, Y int
+}
+
+func (p *namingPoint) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + strconv.Itoa(p.X) + "," + strconv.Itoa(p.Y) + `"`), nil
+}
+
+type namingColor struct {
+	Name string
+}
+
+func (c *namingColor) MarshalText() ([]byte, error) {
+	return []byte("#" + c.Name), nil
+}
+
+type namingShape struct {
+	Center namingPoint
+	Color  namingColor
+}
+
+func TestFieldNaming(tt *testing.T) {
+	t := s.T(tt)
+
+	_ = os.Setenv("LOG_FILE", os.DevNull)
+	defer func() {
+		_ = os.Unsetenv("SERVICE_FIELDNAMING")
+	}()
+
+	cases := []struct {
+		naming  string
+		out     string
+		mapKey  string
+		mapKeys string
+	}{
+		{"", `{"userID":1,"uRLPath":"/a","nick":"Tom","label":"x","createTime":"today"}`, "userName", `{"ID":1,"innerName":2}`},
+		{"lowerCamel", `{"userID":1,"urlPath":"/a","nick":"Tom","Label":"x","createTime":"today"}`, "userName", `{"ID":1,"innerName":2}`},
+		{"snake", `{"user_id":1,"url_path":"/a","nick":"Tom","Label":"x","create_time":"today"}`, "user_name", `{"id":1,"inner_name":2}`},
+		{"asIs", `{"UserID":1,"URLPath":"/a","nick":"Tom","Label":"x","CreateTime":"today"}`, "UserName", `{"ID":1,"InnerName":2}`},
+	}
+	for _, c := range cases {
+		_ = os.Setenv("SERVICE_FIELDNAMING", c.naming)
+		s.ResetAllSets()
+		s.Register(0, "/user", func() namingUser {
+			return namingUser{UserID: 1, URLPath: "/a", NickName: "Tom", Secret: "x", NamingBase: NamingBase{CreateTime: "today"}, Tag: "x"}
+		})
+		s.Register(0, "/map", func() s.Map {
+			return s.Map{"UserName": "Tom", "list": []namingUser{{UserID: 2}}}
+		})
+		s.Register(0, "/shapes", func() []namingShape {
+			return []namingShape{{Center: namingPoint{1, 2}, Color: namingColor{"red"}}}
+		})
+		s.Register(0, "/ints", func() map[string]int {
+			return map[string]int{"ID": 1, "InnerName": 2}
+		})
+
+		as := s.AsyncStart()
+		r := as.Get("/user")
+		t.Test(r.String() == c.out, "output "+c.naming, r.String())
+
+		m := as.Get("/map").Map()
+		list, _ := m["list"].([]interface{})
+		t.Test(m[c.mapKey] == "Tom" && len(list) == 1, "map keys "+c.naming, m)
+
+		r = as.Get("/ints")
+		t.Test(r.String() == c.mapKeys, "map of ints "+c.naming, r.String())
+
+		r = as.Get("/shapes")
+		t.Test(strings.Contains(r.String(), `"1,2"`) && strings.Contains(r.String(), `"#red"`), "pointer marshaler "+c.naming, r.String())
+
+		var out map[string]interface{}
+		for _, api := range s.MakeDocument() {
+			if api.Path == "/user" {
+				out, _ = api.Out.(s.Map)
+			}
+		}
+		t.Test(len(out) == 6 && out["nick"] == "string", "document "+c.naming, out)
+		as.Stop()
+	}
+}
+
+func TestWebsocketNaming(tt *testing.T) {
+	t := s.T(tt)
+
+	_ = os.Setenv("LOG_FILE", os.DevNull)
+	_ = os.Setenv("service_httpVersion", "1")
+	defer func() {
+		_ = os.Unsetenv("service_httpVersion")
+	}()
+	s.ResetAllSets()
+
+	ws := s.RegisterWebsocket(0, "/ws", nil, nil, nil, nil, nil)
+	ws.RegisterAction(0, "user", func() namingUser {
+		return namingUser{UserID: 1, NickName: "Tom"}
+	})
+	ws.RegisterAction(0, "time", func() time.Time {
+		return time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	})
+
+	as := s.AsyncStart()
+	defer as.Stop()
+
+	c, _, err := websocket.DefaultDialer.Dial("ws://"+as.Addr+"/ws", nil)
+	t.Test(err == nil, "websocket connect", err)
+	if err == nil {
+		m := map[string]interface{}{}
+		_ = c.WriteJSON(s.Map{"action": "user"})
+		err = c.ReadJSON(&m)
+		t.Test(err == nil && m["action"] == "user" && m["userID"] == float64(1) && m["nick"] == "Tom", "websocket struct", m, err)
+
+		// 实现了 json.Marshaler 的结构体作为 data 输出
+		m = map[string]interface{}{}
+		_ = c.WriteJSON(s.Map{"action": "time"})
+		err = c.ReadJSON(&m)
+		t.Test(err == nil && m["action"] == "time" && m["data"] == "2020-01-02T03:04:05Z", "websocket marshaler", m, err)
+		_ = c.Close()
+	}
+}
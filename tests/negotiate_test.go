@@ -0,0 +1,89 @@
+package tests
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ssgo/s"
+)
+
+type negotiateUser struct {
+	Id   int
+	Name string
+}
+
+func TestNegotiate(tt *testing.T) {
+	t := s.T(tt)
+
+	_ = os.Setenv("LOG_FILE", os.DevNull)
+	s.ResetAllSets()
+
+	users := func() []negotiateUser {
+		return []negotiateUser{{1, "Tom"}, {2, "Jerry, Jr."}}
+	}
+	s.Restful(0, "GET", "/users", users)
+	s.Restful(0, "GET", "/users.csv", users).SetFormats("csv")
+	s.Restful(0, "GET", "/count", func() int { return 2 })
+	s.Restful(0, "GET", "/hello", func() string { return "hello" })
+	s.Restful(0, "GET", "/any", func(in struct{ Raw bool }) interface{} {
+		if in.Raw {
+			return "raw"
+		}
+		return s.Map{"raw": false}
+	})
+
+	as := s.AsyncStart()
+	defer as.Stop()
+
+	r := as.Get("/users")
+	t.Test(r.Response.Header.Get("Content-Type") == "application/json" && strings.Contains(r.String(), `"name":"Tom"`), "default json", r.Response.Header, r.String())
+
+	// 默认只输出 JSON，浏览器的 Accept 也使用 JSON
+	r = as.Get("/users", "Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+	t.Test(r.Response.Header.Get("Content-Type") == "application/json", "browser accept", r.Response.Header, r.String())
+
+	r = as.Get("/users", "Accept", "application/xml")
+	t.Test(r.Response.StatusCode == 406, "xml not enabled", r.Response.StatusCode)
+
+	r = as.Get("/hello", "Accept", "text/html")
+	t.Test(r.Response.StatusCode == 200 && r.String() == "hello", "raw string", r.Response.StatusCode, r.String())
+
+	r = as.Get("/any?raw=true", "Accept", "text/html")
+	t.Test(r.Response.StatusCode == 200 && r.String() == "raw", "raw interface result", r.Response.StatusCode, r.String())
+
+	r = as.Get("/any", "Accept", "text/html")
+	t.Test(r.Response.StatusCode == 406, "interface result not acceptable", r.Response.StatusCode, r.String())
+
+	s.SetDefaultFormats("json", "xml", "msgpack", "csv", "text")
+
+	r = as.Get("/users", "Accept", "text/csv")
+	t.Test(r.Response.Header.Get("Content-Type") == "text/csv; charset=utf-8" && r.String() == "id,name\n1,Tom\n2,\"Jerry, Jr.\"\n", "csv", r.Response.Header, r.String())
+
+	r = as.Get("/users", "Accept", "text/html, application/xml;q=0.9, application/json;q=0.8")
+	t.Test(r.Response.Header.Get("Content-Type") == "application/xml" && strings.Contains(r.String(), "<result><item><id>1</id><name>Tom</name></item>"), "q values", r.Response.Header, r.String())
+
+	r = as.Get("/users", "Accept", "*/*;q=0.1, application/msgpack")
+	t.Test(r.Response.Header.Get("Content-Type") == "application/msgpack" && len(r.Bytes()) > 0 && r.Bytes()[0] == 0x92, "msgpack", r.Response.Header, r.Bytes())
+
+	r = as.Get("/users", "Accept", "application/*, application/json;q=0")
+	t.Test(r.Response.Header.Get("Content-Type") != "application/json" && strings.HasPrefix(r.Response.Header.Get("Content-Type"), "application/"), "exclude by q=0", r.Response.Header)
+
+	r = as.Get("/users", "Accept", "*/*")
+	t.Test(r.Response.Header.Get("Content-Type") == "application/json", "any", r.Response.Header)
+
+	r = as.Get("/users", "Accept", "image/png")
+	t.Test(r.Response.StatusCode == 406, "not acceptable", r.Response.StatusCode)
+
+	r = as.Get("/users.csv", "Accept", "application/json")
+	t.Test(r.Response.StatusCode == 406, "route formats", r.Response.StatusCode)
+
+	r = as.Get("/users.csv")
+	t.Test(r.Response.Header.Get("Content-Type") == "text/csv; charset=utf-8" && strings.HasPrefix(r.String(), "id,name\n"), "route default format", r.Response.Header, r.String())
+
+	r = as.Get("/count", "Accept", "text/plain")
+	t.Test(r.Response.Header.Get("Content-Type") == "text/plain; charset=utf-8" && r.String() == "2", "text", r.Response.Header, r.String())
+
+	r = as.Get("/users", "Accept", "text/plain")
+	t.Test(r.Response.Header.Get("Content-Type") == "application/json" && strings.HasPrefix(r.String(), "["), "text falls back to json", r.Response.Header, r.String())
+}
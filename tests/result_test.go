@@ -0,0 +1,68 @@
+package tests
+
+import (
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ssgo/s"
+)
+
+func TestResult(tt *testing.T) {
+	t := s.T(tt)
+
+	_ = os.Setenv("LOG_FILE", os.DevNull)
+	s.ResetAllSets()
+
+	s.Restful(0, "POST", "/items", func(in struct{ Name string }) s.Result {
+		return s.Result{
+			Status:  201,
+			Headers: map[string]string{"Location": "/items/1"},
+			Cookies: []*http.Cookie{{Name: "last", Value: in.Name}},
+			Body:    s.Map{"id": 1, "name": in.Name},
+		}
+	})
+	s.Restful(0, "GET", "/old", func() *s.Result {
+		return s.Redirect(301, "/new")
+	})
+	s.Restful(0, "DELETE", "/items/{id}", func() *s.Result {
+		return s.NoContent()
+	})
+	s.Restful(0, "GET", "/export", func() *s.Result {
+		return s.Download("报表.csv", []byte("a,b\n1,2\n"))
+	})
+	s.Restful(0, "GET", "/filtered", func() *s.Result {
+		return &s.Result{Status: 202, Body: s.Map{"ok": true}}
+	})
+	s.SetOutFilter(func(in *map[string]interface{}, request *http.Request, response *http.ResponseWriter, result interface{}) (interface{}, bool) {
+		if m, ok := result.(s.Map); ok && m["ok"] == true {
+			m["filtered"] = true
+		}
+		return nil, false
+	})
+
+	as := s.AsyncStart()
+	defer as.Stop()
+
+	r := as.Post("/items", s.Map{"name": "Tom"})
+	d := r.Map()
+	t.Test(r.Response.StatusCode == 201 && d["name"] == "Tom", "status and body", r.Response.StatusCode, r.String())
+	t.Test(r.Response.Header.Get("Location") == "/items/1" && strings.Contains(r.Response.Header.Get("Set-Cookie"), "last=Tom"), "headers and cookies", r.Response.Header)
+	t.Test(strings.HasPrefix(r.Response.Header.Get("Content-Type"), "application/json"), "body encoded", r.Response.Header)
+
+	r = as.Get("/old")
+	t.Test(r.Response.StatusCode == 301 && r.Response.Header.Get("Location") == "/new", "redirect", r.Response.StatusCode, r.Response.Header)
+
+	r = as.Do("DELETE", "/items/1", nil)
+	t.Test(r.Response.StatusCode == 204 && r.String() == "", "no content", r.Response.StatusCode, r.String())
+
+	r = as.Get("/export")
+	disposition := r.Response.Header.Get("Content-Disposition")
+	t.Test(r.String() == "a,b\n1,2\n" && strings.HasPrefix(r.Response.Header.Get("Content-Type"), "text/csv"), "download", r.Response.Header, r.String())
+	t.Test(strings.HasPrefix(disposition, "attachment; filename=") && strings.Contains(disposition, "filename*=UTF-8''%E6%8A%A5%E8%A1%A8.csv"), "content disposition", disposition)
+
+	r = as.Get("/filtered")
+	d = r.Map()
+	t.Test(r.Response.StatusCode == 202 && d["filtered"] == true, "out filter on body", r.Response.StatusCode, r.String())
+}
@@ -0,0 +1,60 @@
+package tests
+
+import (
+	"os"
+	"testing"
+
+	"github.com/ssgo/s"
+)
+
+func TestRouteCheck(tt *testing.T) {
+	t := s.T(tt)
+
+	_ = os.Setenv("LOG_FILE", os.DevNull)
+	_ = os.Setenv("SERVICE_FAILONROUTEISSUES", "true")
+	defer func() {
+		_ = os.Unsetenv("SERVICE_FAILONROUTEISSUES")
+	}()
+
+	s.ResetAllSets()
+	s.Register(0, "/a", func() string { return "a1" })
+	s.Register(0, "/a", func() string { return "a2" })
+	as := s.AsyncStart()
+	t.Test(as.Addr == "", "fail on duplicate route", as.Addr)
+
+	s.ResetAllSets()
+	s.Rewrite("/r/(.+)", "/a/$1")
+	s.Rewrite("/r/(.+)", "/b/$1")
+	as = s.AsyncStart()
+	t.Test(as.Addr == "", "fail on duplicate regex rewrite", as.Addr)
+
+	s.ResetAllSets()
+	s.Register(0, "/x/{a}/foo", func() string { return "a" })
+	s.Register(0, "/x/{b}/foo", func() string { return "b" })
+	as = s.AsyncStart()
+	t.Test(as.Addr == "", "fail on conflict route", as.Addr)
+
+	s.ResetAllSets()
+	s.RegisterWithPriority(0, 1, "/files/{path*}", func() string { return "files" })
+	s.Register(0, "/files/special", func() string { return "special" })
+	as = s.AsyncStart()
+	t.Test(as.Addr == "", "fail on shadowed route", as.Addr)
+
+	s.ResetAllSets()
+	echo := func() string { return "echo" }
+	s.Register(0, "/echo", echo)
+	s.Register(0, "/echo", echo)
+	s.RegisterWithPriority(0, 1, "/x/{a:int}/foo", func() string { return "a" })
+	s.Register(0, "/x/{b}/foo", func() string { return "b" })
+	s.Register(0, "/files/{path*}", func() string { return "files" })
+	s.RegisterWithPriority(0, 1, "/files/{name}/info", func() string { return "info" })
+	s.Register(0, "/user/{id:int}", func() string { return "id" })
+	s.Register(0, "/user/{name}", func() string { return "name" })
+	as = s.AsyncStart()
+	defer as.Stop()
+	t.Test(as.Addr != "", "start without route issues", as.Addr)
+	r := as.Get("/x/1/foo").String()
+	t.Test(r == "a", "conflict resolved by priority", r)
+	r = as.Get("/x/abc/foo").String()
+	t.Test(r == "b", "fallback to lower priority", r)
+}
@@ -0,0 +1,149 @@
+package tests
+
+import (
+	"os"
+	"testing"
+
+	"github.com/ssgo/s"
+)
+
+func TestRouter(tt *testing.T) {
+	t := s.T(tt)
+
+	_ = os.Setenv("LOG_FILE", os.DevNull)
+	s.ResetAllSets()
+	s.Register(0, "/files/{path*}", func(in struct{ Path string }) string {
+		return "files " + in.Path
+	})
+	s.Register(0, "/users/{id}", func(in struct{ Id string }) string {
+		return "user " + in.Id
+	})
+	s.Register(0, "/users/{id}.json", func(in struct{ Id string }) string {
+		return "json " + in.Id
+	})
+	s.Register(0, "/users/me", func() string {
+		return "me"
+	})
+	s.Register(0, "/files/special", func() string {
+		return "special"
+	})
+	s.Restful(0, "GET", "/users/{id}/orders/{orderId}", func(in struct{ Id, OrderId string }) string {
+		return "order " + in.Id + " " + in.OrderId
+	})
+	as := s.AsyncStart()
+	defer as.Stop()
+
+	r := as.Get("/users/me").String()
+	t.Test(r == "me", "static before param", r)
+
+	r = as.Get("/users/abc").String()
+	t.Test(r == "user abc", "param", r)
+
+	r = as.Get("/users/abc.json").String()
+	t.Test(r == "json abc", "mixed segment", r)
+
+	r = as.Get("/users/a%20b").String()
+	t.Test(r == "user a b", "unescaped param", r)
+
+	r = as.Get("/users/abc/orders/99").String()
+	t.Test(r == "order abc 99", "multiple params", r)
+
+	res := as.Get("/users/abc/def")
+	t.Test(res.Response.StatusCode == 404, "param not match slash", res.Response.StatusCode)
+
+	r = as.Get("/files/a/b/c.txt").String()
+	t.Test(r == "files a/b/c.txt", "catch all", r)
+
+	r = as.Get("/files/special").String()
+	t.Test(r == "special", "static before catch all", r)
+}
+
+func TestRouterTypedArgs(tt *testing.T) {
+	t := s.T(tt)
+
+	_ = os.Setenv("LOG_FILE", os.DevNull)
+	s.ResetAllSets()
+	s.Register(0, "/user/{id:int}", func(in struct{ Id int }) int {
+		return in.Id * 2
+	})
+	s.Register(0, "/user/{slug:[a-z-]+}", func(in struct{ Slug string }) string {
+		return "slug " + in.Slug
+	})
+	s.Register(0, "/item/{uuid:uuid}", func(in struct{ Uuid string }) string {
+		return in.Uuid
+	})
+	s.Register(0, "/code/{code:[0-9]{4}}.{ext:alpha}", func(in struct{ Code, Ext string }) string {
+		return in.Code + " " + in.Ext
+	})
+	as := s.AsyncStart()
+	defer as.Stop()
+
+	r := as.Get("/user/21").String()
+	t.Test(r == "42", "int arg", r)
+
+	r = as.Get("/user/jim-green").String()
+	t.Test(r == "slug jim-green", "fall through to regex arg", r)
+
+	res := as.Get("/user/Jim")
+	t.Test(res.Response.StatusCode == 404, "no arg matched", res.Response.StatusCode)
+
+	res = as.Get("/user/abc/def")
+	t.Test(res.Response.StatusCode == 404, "arg not match slash", res.Response.StatusCode)
+
+	r = as.Get("/item/0a1b2c3d-0000-4000-8000-00000000abcd").String()
+	t.Test(r == "0a1b2c3d-0000-4000-8000-00000000abcd", "uuid arg", r)
+
+	res = as.Get("/item/0a1b2c3d")
+	t.Test(res.Response.StatusCode == 404, "bad uuid", res.Response.StatusCode)
+
+	r = as.Get("/code/1234.json").String()
+	t.Test(r == "1234 json", "mixed typed args", r)
+
+	res = as.Get("/code/123.json")
+	t.Test(res.Response.StatusCode == 404, "bad mixed typed args", res.Response.StatusCode)
+}
+
+func TestRouterPriority(tt *testing.T) {
+	t := s.T(tt)
+
+	_ = os.Setenv("LOG_FILE", os.DevNull)
+	s.ResetAllSets()
+	s.RegisterWithPriority(0, 10, "/files/{path*}", func(in struct{ Path string }) string {
+		return "files " + in.Path
+	})
+	s.Register(0, "/files/special", func() string {
+		return "special"
+	})
+	s.Register(0, "/docs/{path*}", func(in struct{ Path string }) string {
+		return "docs " + in.Path
+	})
+	s.Register(0, "/docs/special", func() string {
+		return "special"
+	})
+	s.Register(0, "/user/{id:int}", func(in struct{ Id int }) int {
+		return in.Id
+	})
+	s.RegisterWithPriority(0, 1, "/user/{name}/info", func(in struct{ Name string }) string {
+		return "name " + in.Name
+	})
+	s.Register(0, "/user/{id:int}/info", func(in struct{ Id int }) string {
+		return "id info"
+	})
+	as := s.AsyncStart()
+	defer as.Stop()
+
+	r := as.Get("/files/special").String()
+	t.Test(r == "files special", "priority before specificity", r)
+
+	r = as.Get("/docs/special").String()
+	t.Test(r == "special", "specificity with same priority", r)
+
+	r = as.Get("/docs/a/b").String()
+	t.Test(r == "docs a/b", "catch all", r)
+
+	r = as.Get("/user/12/info").String()
+	t.Test(r == "name 12", "priority before typed arg", r)
+
+	r = as.Get("/user/12").String()
+	t.Test(r == "12", "typed arg", r)
+}
@@ -0,0 +1,74 @@
+package tests
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/ssgo/s"
+)
+
+func TestRuntimeRegister(tt *testing.T) {
+	t := s.T(tt)
+
+	_ = os.Setenv("LOG_FILE", os.DevNull)
+	s.ResetAllSets()
+	s.Register(0, "/stable", func() string {
+		return "stable"
+	})
+	as := s.AsyncStart()
+	defer as.Stop()
+
+	r := as.Get("/dynamic")
+	t.Test(r.Response.StatusCode == 404, "not registered", r.Response.StatusCode)
+
+	s.Restful(0, "GET", "/dynamic", func() string {
+		return "dynamic"
+	})
+	r = as.Get("/dynamic")
+	t.Test(r.String() == "dynamic", "register after start", r.String())
+
+	s.Unregister("GET", "/dynamic")
+	r = as.Get("/dynamic")
+	t.Test(r.Response.StatusCode == 404, "unregister", r.Response.StatusCode)
+
+	s.RegisterWebsocket(0, "/dynamicWs", nil, nil, nil, nil, nil)
+	s.UnregisterWebsocket("/dynamicWs")
+	r = as.Get("/dynamicWs")
+	t.Test(r.Response.StatusCode == 404, "unregister websocket", r.Response.StatusCode)
+
+	// 请求的同时不断注册和删除服务
+	stop := make(chan bool)
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			path := fmt.Sprintf("/tmp/%d", i%10)
+			s.Register(0, path, func() string {
+				return path
+			})
+			s.Static(path+"/static/", "www/")
+			s.Rewrite(path+"/r", path)
+			s.Proxy(path+"/p", "none", path)
+			s.RemoveProxy(path + "/p")
+			s.Unregister("", path)
+		}
+	}()
+
+	failed := 0
+	for i := 0; i < 200; i++ {
+		if as.Get("/stable").String() != "stable" {
+			failed++
+		}
+	}
+	close(stop)
+	wg.Wait()
+	t.Test(failed == 0, "requests while updating routes", failed)
+}
@@ -0,0 +1,80 @@
+package tests
+
+import (
+	"bufio"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ssgo/s"
+)
+
+func readSSELines(reader *bufio.Reader, n int) []string {
+	lines := make([]string, 0, n)
+	for len(lines) < n {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			break
+		}
+		lines = append(lines, strings.TrimRight(line, "\n"))
+	}
+	return lines
+}
+
+func TestSSE(tt *testing.T) {
+	t := s.T(tt)
+
+	_ = os.Setenv("LOG_FILE", os.DevNull)
+	_ = os.Setenv("service_httpVersion", "1")
+	defer func() {
+		_ = os.Unsetenv("service_httpVersion")
+	}()
+	s.ResetAllSets()
+
+	s.RegisterSSE(0, "/events/{room}", func(in struct{ Room string }, sender *s.SSESender) {
+		_ = sender.Send("hello", s.Map{"room": in.Room})
+		_ = sender.SendEvent(s.SSEEvent{Id: "2", Data: "a\nb\rid: 9\r\nc", Retry: 3000})
+		_ = sender.Send("", "resume from "+sender.LastEventId())
+	})
+	s.RegisterSSE(0, "/wait", func(sender *s.SSESender) {
+		_ = sender.Send("ready", "")
+		<-sender.Done()
+	}).SetHeartbeat(50)
+
+	as := s.AsyncStart()
+	stopped := false
+	defer func() {
+		if !stopped {
+			as.Stop()
+		}
+	}()
+
+	req, _ := http.NewRequest("GET", "http://"+as.Addr+"/events/r1", nil)
+	req.Header.Set("Last-Event-ID", "1")
+	res, err := http.DefaultClient.Do(req)
+	t.Test(err == nil && res.Header.Get("Content-Type") == "text/event-stream" && res.Header.Get("Cache-Control") == "no-cache", "sse headers", err)
+	if err != nil {
+		return
+	}
+	lines := readSSELines(bufio.NewReader(res.Body), 12)
+	_ = res.Body.Close()
+	t.Test(strings.Join(lines, "|") == `event: hello|data: {"room":"r1"}||id: 2|retry: 3000|data: a|data: b|data: id: 9|data: c||data: resume from 1|`, "events", lines)
+
+	res, err = http.Get("http://" + as.Addr + "/wait")
+	t.Test(err == nil, "wait stream", err)
+	if err != nil {
+		return
+	}
+	reader := bufio.NewReader(res.Body)
+	lines = readSSELines(reader, 5)
+	t.Test(len(lines) == 5 && lines[0] == "event: ready" && lines[3] == ": ping", "heartbeat", lines)
+
+	stopStart := time.Now()
+	as.Stop()
+	stopped = true
+	_, _ = reader.ReadString(0)
+	_ = res.Body.Close()
+	t.Test(time.Since(stopStart) < 2*time.Second, "close streams on stop", time.Since(stopStart))
+}
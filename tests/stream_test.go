@@ -0,0 +1,120 @@
+package tests
+
+import (
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ssgo/s"
+)
+
+func getRaw(url string, headers ...string) (*http.Response, []byte) {
+	req, _ := http.NewRequest("GET", url, nil)
+	for i := 1; i < len(headers); i += 2 {
+		req.Header.Set(headers[i-1], headers[i])
+	}
+	res, err := http.DefaultTransport.RoundTrip(req)
+	if err != nil {
+		return nil, []byte(err.Error())
+	}
+	data, _ := ioutil.ReadAll(res.Body)
+	_ = res.Body.Close()
+	return res, data
+}
+
+func TestStream(tt *testing.T) {
+	t := s.T(tt)
+
+	_ = os.Setenv("LOG_FILE", os.DevNull)
+	_ = os.Setenv("service_httpVersion", "1")
+	_ = os.Setenv("service_compress", "true")
+	defer func() {
+		_ = os.Unsetenv("service_httpVersion")
+		_ = os.Unsetenv("service_compress")
+	}()
+	s.ResetAllSets()
+
+	bigText := strings.Repeat("0123456789", 500000)
+	s.Restful(0, "GET", "/export", func() io.Reader {
+		return strings.NewReader(bigText)
+	})
+	s.Restful(0, "GET", "/users", func() <-chan s.Map {
+		ch := make(chan s.Map)
+		go func() {
+			for i := 1; i <= 3; i++ {
+				ch <- s.Map{"id": i}
+			}
+			close(ch)
+		}()
+		return ch
+	}).SetFormats("json", "ndjson")
+	s.Restful(0, "GET", "/numbers", func() func(yield func(int) bool) {
+		return func(yield func(int) bool) {
+			for i := 1; i <= 3; i++ {
+				if !yield(i) {
+					return
+				}
+			}
+		}
+	})
+	produced := make(chan bool, 1)
+	s.Restful(0, "GET", "/produce", func() <-chan int {
+		ch := make(chan int)
+		go func() {
+			for i := 1; i <= 3; i++ {
+				ch <- i
+			}
+			close(ch)
+			produced <- true
+		}()
+		return ch
+	})
+	s.Restful(0, "GET", "/empty", func() chan int {
+		ch := make(chan int)
+		close(ch)
+		return ch
+	})
+
+	as := s.AsyncStart()
+	defer as.Stop()
+
+	res, data := getRaw("http://"+as.Addr+"/export", "Accept-Encoding", "gzip")
+	t.Test(res.Header.Get("Content-Encoding") == "gzip" && res.Header.Get("Content-Length") == "", "gzip stream", res.Header)
+	t.Test(len(res.TransferEncoding) == 1 && res.TransferEncoding[0] == "chunked", "chunked", res.TransferEncoding)
+	zipReader, err := gzip.NewReader(strings.NewReader(string(data)))
+	unzipped := []byte{}
+	if err == nil {
+		unzipped, _ = ioutil.ReadAll(zipReader)
+	}
+	t.Test(string(unzipped) == bigText, "reader content", len(data), len(unzipped))
+
+	res, data = getRaw("http://" + as.Addr + "/export")
+	t.Test(string(data) == bigText && res.Header.Get("Content-Type") == "application/octet-stream", "reader without gzip", len(data), res.Header)
+
+	res, data = getRaw("http://" + as.Addr + "/users")
+	t.Test(string(data) == `[{"id":1},{"id":2},{"id":3}]` && res.Header.Get("Content-Type") == "application/json", "json array", string(data), res.Header)
+
+	res, data = getRaw("http://"+as.Addr+"/users", "Accept", "application/x-ndjson")
+	t.Test(string(data) == "{\"id\":1}\n{\"id\":2}\n{\"id\":3}\n" && res.Header.Get("Content-Type") == "application/x-ndjson", "ndjson", string(data), res.Header)
+
+	r := as.Get("/numbers")
+	t.Test(r.String() == "[1,2,3]", "iterator", r.String())
+
+	r = as.Get("/empty")
+	t.Test(r.String() == "[]", "empty channel", r.String())
+
+	// HEAD 请求不输出内容，写入 channel 的 goroutine 不会一直阻塞
+	req, _ := http.NewRequest("HEAD", "http://"+as.Addr+"/produce", nil)
+	res, err = http.DefaultClient.Do(req)
+	finished := false
+	select {
+	case finished = <-produced:
+	case <-time.After(time.Second):
+	}
+	t.Test(err == nil && res.StatusCode == 200 && finished, "head drains channel", err, finished)
+}
@@ -0,0 +1,87 @@
+package tests
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ssgo/discover"
+	"github.com/ssgo/s"
+)
+
+func TestTimeout(tt *testing.T) {
+	t := s.T(tt)
+
+	_ = os.Setenv("LOG_FILE", os.DevNull)
+	s.ResetAllSets()
+
+	s.Restful(0, "GET", "/slow", func(ctx context.Context) string {
+		select {
+		case <-ctx.Done():
+			return "canceled"
+		case <-time.After(500 * time.Millisecond):
+			return "done"
+		}
+	}).SetTimeout(50)
+	s.Restful(0, "GET", "/sleep", func() string {
+		time.Sleep(300 * time.Millisecond)
+		return "done"
+	})
+	s.Restful(0, "GET", "/hold", func() string {
+		time.Sleep(300 * time.Millisecond)
+		return "done"
+	}).SetTimeout(50)
+	s.Restful(0, "GET", "/fast", func(ctx context.Context, caller *discover.Caller) s.Map {
+		deadline, hasDeadline := ctx.Deadline()
+		callerDeadline, _ := caller.Request.Context().Deadline()
+		return s.Map{"hasDeadline": hasDeadline, "callerDeadline": callerDeadline.Equal(deadline), "timeout": caller.Request.Header.Get("X-Timeout")}
+	}).SetTimeout(1000)
+	callErrors := make(chan error, 1)
+	s.Restful(0, "GET", "/call", func(caller *s.Caller) string {
+		time.Sleep(100 * time.Millisecond)
+		callErrors <- caller.Get("app", "/").Error
+		return "done"
+	}).SetTimeout(50)
+	s.Restful(0, "GET", "/ctx-error", func(ctx context.Context) (string, error) {
+		<-ctx.Done()
+		return "", ctx.Err()
+	})
+
+	as := s.AsyncStart()
+	defer as.Stop()
+
+	r := as.Get("/slow")
+	t.Test(r.Response.StatusCode == 504 && r.Map()["message"] == "Gateway Timeout", "route timeout", r.Response.StatusCode, r.String())
+
+	// 服务不使用 context 时超时后立即输出 504，不等待服务返回
+	startTime := time.Now()
+	r = as.Get("/hold")
+	t.Test(r.Response.StatusCode == 504 && time.Since(startTime) < 250*time.Millisecond, "timeout without context", r.Response.StatusCode, r.String(), time.Since(startTime))
+
+	r = as.Get("/sleep")
+	t.Test(r.Response.StatusCode == 200 && r.String() == "done", "no timeout", r.Response.StatusCode, r.String())
+
+	startTime = time.Now()
+	r = as.Get("/sleep", "X-Timeout", "50", "X-From-App", "upstream")
+	t.Test(r.Response.StatusCode == 504 && time.Since(startTime) < 250*time.Millisecond, "upstream timeout", r.Response.StatusCode, r.String(), time.Since(startTime))
+
+	// 不是其他服务的调用时不使用 X-Timeout
+	r = as.Get("/sleep", "X-Timeout", "50")
+	t.Test(r.Response.StatusCode == 200 && r.String() == "done", "client timeout ignored", r.Response.StatusCode, r.String())
+
+	// X-Timeout 不能延长服务的超时时间
+	r = as.Get("/slow", "X-Timeout", "5000", "X-From-App", "upstream")
+	t.Test(r.Response.StatusCode == 504, "upstream timeout can not extend", r.Response.StatusCode, r.String())
+
+	d := as.Get("/fast").Map()
+	t.Test(d["hasDeadline"] == true && d["callerDeadline"] == true && d["timeout"] != "" && d["timeout"] != "0", "deadline to caller", d)
+
+	// 超时后 s.Caller 不再调用其他服务
+	r = as.Get("/call")
+	err := <-callErrors
+	t.Test(r.Response.StatusCode == 504 && err == context.DeadlineExceeded, "caller after deadline", r.Response.StatusCode, err)
+
+	r = as.Get("/ctx-error", "X-Timeout", "50", "X-From-App", "upstream")
+	t.Test(r.Response.StatusCode == 504, "context error", r.Response.StatusCode, r.String())
+}
@@ -0,0 +1,95 @@
+package tests
+
+import (
+	"bytes"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ssgo/s"
+)
+
+func postMultipart(url string, fields map[string]string, files map[string][]string) (*http.Response, string) {
+	body := new(bytes.Buffer)
+	writer := multipart.NewWriter(body)
+	for k, v := range fields {
+		_ = writer.WriteField(k, v)
+	}
+	for k, contents := range files {
+		for i, content := range contents {
+			fw, _ := writer.CreateFormFile(k, k+string(rune('a'+i))+".txt")
+			_, _ = fw.Write([]byte(content))
+		}
+	}
+	_ = writer.Close()
+	res, err := http.Post(url, writer.FormDataContentType(), body)
+	if err != nil {
+		return nil, err.Error()
+	}
+	data, _ := ioutil.ReadAll(res.Body)
+	_ = res.Body.Close()
+	return res, string(data)
+}
+
+func TestUpload(tt *testing.T) {
+	t := s.T(tt)
+
+	_ = os.Setenv("LOG_FILE", os.DevNull)
+	_ = os.Setenv("service_httpVersion", "1")
+	_ = os.Setenv("SERVICE_UPLOADMEMORYSIZE", "16")
+	_ = os.Setenv("SERVICE_UPLOADMAXSIZE", "4096")
+	defer func() {
+		_ = os.Unsetenv("service_httpVersion")
+		_ = os.Unsetenv("SERVICE_UPLOADMEMORYSIZE")
+		_ = os.Unsetenv("SERVICE_UPLOADMAXSIZE")
+	}()
+	s.ResetAllSets()
+
+	s.Restful(0, "POST", "/upload", func(in struct {
+		Title  string
+		Avatar *s.UploadFile `required:"true" max:"1024"`
+		Photos []s.UploadFile
+	}) s.Map {
+		content, err := in.Avatar.Content()
+		photos := make([]string, 0)
+		for _, photo := range in.Photos {
+			data, _ := photo.Content()
+			photos = append(photos, photo.Name+":"+string(data))
+		}
+		return s.Map{"title": in.Title, "name": in.Avatar.Name, "size": in.Avatar.Size, "content": string(content), "photos": strings.Join(photos, ","), "error": err}
+	})
+
+	as := s.AsyncStart()
+	defer as.Stop()
+
+	big := strings.Repeat("x", 100)
+	res, body := postMultipart("http://"+as.Addr+"/upload", map[string]string{"title": "hello"}, map[string][]string{
+		"avatar": {big},
+		"photos": {"p1", "p2"},
+	})
+	t.Test(res != nil && res.StatusCode == 200, "upload", body)
+	t.Test(strings.Contains(body, `"title":"hello"`) && strings.Contains(body, `"name":"avatara.txt"`) && strings.Contains(body, `"size":100`), "upload fields", body)
+	t.Test(strings.Contains(body, `"content":"`+big+`"`), "read file from disk", body)
+	t.Test(strings.Contains(body, `"photos":"photosa.txt:p1,photosb.txt:p2"`), "upload files", body)
+
+	res, body = postMultipart("http://"+as.Addr+"/upload", map[string]string{"title": "hello"}, nil)
+	t.Test(res != nil && res.StatusCode == 400 && strings.Contains(body, "avatar"), "required upload", body)
+
+	res, body = postMultipart("http://"+as.Addr+"/upload", nil, map[string][]string{"avatar": {strings.Repeat("x", 2000)}})
+	t.Test(res != nil && res.StatusCode == 400 && strings.Contains(body, `"rule":"max"`), "upload file size", body)
+
+	res, body = postMultipart("http://"+as.Addr+"/upload", nil, map[string][]string{"avatar": {strings.Repeat("x", 5000)}})
+	t.Test(res != nil && res.StatusCode == 413, "upload too large", res, body)
+
+	found := false
+	for _, api := range s.MakeDocument() {
+		if api.Path == "/upload" {
+			in, _ := api.In.(s.Map)
+			found = strings.HasPrefix(in["Avatar"].(string), "file") && in["Photos"] == "[]file"
+		}
+	}
+	t.Test(found, "upload in document", s.MakeDocument())
+}
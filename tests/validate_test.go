@@ -0,0 +1,135 @@
+package tests
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/ssgo/s"
+)
+
+type validateAddress struct {
+	City string `required:"true"`
+	Zip  string `regex:"^\\d{6}$"`
+}
+
+type validateUserArgs struct {
+	Name      string   `required:"true" length:"2,10"`
+	Age       int      `min:"1" max:"150"`
+	Gender    string   `enum:"male,female"`
+	Email     string   `email:"true"`
+	Tags      []string `length:",3" regex:"^[a-z]+$"`
+	Address   validateAddress
+	Addresses []validateAddress
+	Code      string `check:"^[A-Z]+$"`
+	Note      string `doc:"double check it"`
+	PostCode  string `json:"post_code" length:"6"`
+}
+
+func findFieldError(fields []interface{}, field, rule string) bool {
+	for _, item := range fields {
+		m, ok := item.(map[string]interface{})
+		if ok && m["field"] == field && m["rule"] == rule {
+			return true
+		}
+	}
+	return false
+}
+
+func TestValidate(tt *testing.T) {
+	t := s.T(tt)
+
+	_ = os.Setenv("LOG_FILE", os.DevNull)
+	_ = os.Setenv("service_httpVersion", "1")
+	defer func() {
+		_ = os.Unsetenv("service_httpVersion")
+	}()
+	s.ResetAllSets()
+	s.Restful(0, "POST", "/user", func(in validateUserArgs) string {
+		return "ok:" + in.Name
+	})
+	// 之前的 `check ^\d+$` 格式，运行时生成结构体避免 go vet 的 structtag 检查
+	legacyType := reflect.StructOf([]reflect.StructField{{Name: "Code", Type: reflect.TypeOf(""), Tag: reflect.StructTag(`check ^\d+$`)}})
+	s.Restful(0, "POST", "/legacy", reflect.MakeFunc(reflect.FuncOf([]reflect.Type{legacyType}, []reflect.Type{reflect.TypeOf("")}, false), func(args []reflect.Value) []reflect.Value {
+		return []reflect.Value{reflect.ValueOf("ok")}
+	}).Interface())
+	ws := s.RegisterWebsocket(0, "/ws/{name}", nil, func(in struct {
+		Name string `length:"3,"`
+	}) {
+	}, nil, nil, nil)
+	ws.RegisterAction(0, "hello", func(in struct {
+		Name string `required:"true"`
+	}) string {
+		return "hello " + in.Name
+	})
+
+	as := s.AsyncStart()
+	defer as.Stop()
+
+	r := as.Post("/user", s.Map{"name": "Tom", "age": 20, "gender": "male", "email": "tom@abc.com", "address": s.Map{"city": "bj", "zip": "100000"}})
+	t.Test(r.Response.StatusCode == 200 && r.String() == "ok:Tom", "valid input", r.Response.StatusCode, r.String())
+
+	r = as.Post("/user", s.Map{
+		"age":       200,
+		"gender":    "x",
+		"email":     "abc",
+		"tags":      s.Arr{"a", "B", "c", "d"},
+		"address":   s.Map{"zip": "12"},
+		"addresses": s.Arr{s.Map{"city": "sh"}, s.Map{"zip": "200000"}},
+		"code":      "abc",
+		"postCode":  "12",
+		"note":      "x",
+	})
+	d := r.Map()
+	fields, _ := d["fields"].([]interface{})
+	t.Test(r.Response.StatusCode == 400, "invalid input status", r.Response.StatusCode)
+	t.Test(findFieldError(fields, "name", "required"), "required", d)
+	t.Test(findFieldError(fields, "age", "max"), "max", d)
+	t.Test(findFieldError(fields, "gender", "enum"), "enum", d)
+	t.Test(findFieldError(fields, "email", "email"), "email", d)
+	t.Test(findFieldError(fields, "tags", "length") && findFieldError(fields, "tags[1]", "regex"), "slice", d)
+	t.Test(findFieldError(fields, "address.city", "required") && findFieldError(fields, "address.zip", "regex"), "nested struct", d)
+	t.Test(findFieldError(fields, "addresses[1].city", "required") && !findFieldError(fields, "addresses[0].city", "required"), "nested slice", d)
+	t.Test(findFieldError(fields, "code", "regex"), "check tag", d)
+	t.Test(findFieldError(fields, "post_code", "length"), "field name from json tag", d)
+	t.Test(!findFieldError(fields, "note", "regex"), "check in other tag value", d)
+
+	r = as.Post("/user", s.Map{"name": "T", "age": 1, "address": s.Map{"city": "bj"}})
+	fields, _ = r.Map()["fields"].([]interface{})
+	t.Test(r.Response.StatusCode == 400 && len(fields) == 1 && findFieldError(fields, "name", "length"), "length", r.String())
+
+	r = as.Post("/legacy", s.Map{"code": "abc"})
+	fields, _ = r.Map()["fields"].([]interface{})
+	t.Test(r.Response.StatusCode == 400 && findFieldError(fields, "code", "regex"), "legacy check tag", r.String())
+	r = as.Post("/legacy", s.Map{"code": "123"})
+	t.Test(r.Response.StatusCode == 200 && r.String() == "ok", "legacy check tag pass", r.String())
+
+	_, res, err := websocket.DefaultDialer.Dial("ws://"+as.Addr+"/ws/ab", nil)
+	t.Test(err != nil && res != nil && res.StatusCode == 400, "websocket open validate", err)
+
+	c, _, err := websocket.DefaultDialer.Dial("ws://"+as.Addr+"/ws/abc", nil)
+	t.Test(err == nil, "websocket connect", err)
+	if err == nil {
+		m := map[string]interface{}{}
+		_ = c.WriteJSON(s.Map{"action": "hello"})
+		err = c.ReadJSON(&m)
+		fields, _ = m["fields"].([]interface{})
+		t.Test(err == nil && m["action"] == "hello" && findFieldError(fields, "name", "required"), "websocket action validate", m, err)
+
+		m = map[string]interface{}{}
+		_ = c.WriteJSON(s.Map{"action": "hello", "name": "Tom"})
+		err = c.ReadJSON(&m)
+		t.Test(err == nil && m["data"] == "hello Tom", "websocket action", m, err)
+		_ = c.Close()
+	}
+
+	found := false
+	for _, api := range s.MakeDocument() {
+		if api.Path == "/user" {
+			in, _ := api.In.(s.Map)
+			found = in["Name"] == "string required length:between 2 and 10" && in["Age"] == "int min:1 max:150"
+		}
+	}
+	t.Test(found, "rules in document", s.MakeDocument())
+}